@@ -10,6 +10,8 @@ import (
 // Returns nil if access is allowed
 //
 // Returns ActionReult if access is denied. In this case ActionReult is used to response
+//
+// OPTIONS requests of actions without OPTIONS handlers are not checked, see SetPreflightHandler.
 type AccessCheckerInterface interface {
 	IsAccessAllowed(c Controller, a Action, response http.ResponseWriter, request *http.Request) ActionResultInterface
 }
@@ -1,9 +1,12 @@
 package trinity
 
 import (
-	"errors"
+	"bytes"
+	"html/template"
 	"net/http"
 	"encoding/json"
+	"encoding/xml"
+	"strings"
 )
 
 // ActionResultInterface generates the http-response using a given mvc infrastructure,
@@ -75,23 +78,244 @@ func (result *NotFoundActionResult) Response(mvcI *MvcInfrastructure, c Controll
 		Response(mvcI, mvcI.notFoundView.C, mvcI.notFoundView.A, response, request)
 }
 
+// Method-not-allowed action result. Sets the Allow header.
+type MethodNotAllowedActionResult struct {
+	Method  string   // http-method of the request
+	Allowed []string // http-methods the action has handlers for
+}
+
+// Generates a method-not-allowed action result
+func MethodNotAllowedResult(allowed []string) ActionResultInterface {
+	logger.Trace("")
+	logger.Debugf("allowed: %v", allowed)
+
+	return &MethodNotAllowedActionResult{"", allowed}
+}
+func (result *MethodNotAllowedActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	result.Method = request.Method
+
+	response.Header().Set("Allow", strings.Join(result.Allowed, ", "))
+	response.WriteHeader(405)
+
+	if mvcI.methodNotAllowedView == nil {
+		defaultMethodNotAllowed(response, request)
+		return
+	}
+
+	ShowView(mvcI.methodNotAllowedView.C, mvcI.methodNotAllowedView.A, result).
+		Response(mvcI, mvcI.methodNotAllowedView.C, mvcI.methodNotAllowedView.A, response, request)
+}
+
+// Action result that answers OPTIONS requests with the Allow header. Uses the preflight
+// handler if it is set, see MvcInfrastructure.SetPreflightHandler
+type OptionsActionResult struct {
+	Allowed []string // http-methods the action answers
+}
+
+// Generates an options action result
+func OptionsResult(allowed []string) ActionResultInterface {
+	logger.Trace("")
+	logger.Debugf("allowed: %v", allowed)
+
+	return &OptionsActionResult{allowed}
+}
+func (result *OptionsActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	response.Header().Set("Allow", strings.Join(result.Allowed, ", "))
+
+	if mvcI.preflightHandler != nil {
+		res := mvcI.preflightHandler.HandlePreflight(c, a, result.Allowed, response, request)
+		if res != nil {
+			res.Response(mvcI, c, a, response, request)
+			return
+		}
+	}
+
+	response.WriteHeader(200)
+}
+
+// Action result that sends the status code. Shows the view set by MvcInfrastructure.SetStatusView
+// for the code if any (the view gets the result as the vm), otherwise sends the message as plain text
+// (the status text for error codes without a message).
+type StatusActionResult struct {
+	Code    int
+	Message string
+}
+
+// Generates a status action result
+func StatusResult(code int) ActionResultInterface {
+	logger.Trace("")
+	logger.Debugf("code: %v", code)
+
+	return &StatusActionResult{code, ""}
+}
+
+// Generates a 400 status action result
+func BadRequestResult(message string) ActionResultInterface {
+	logger.Trace("")
+
+	return &StatusActionResult{http.StatusBadRequest, message}
+}
+
+// Generates a 401 status action result
+func UnauthorizedResult(message string) ActionResultInterface {
+	logger.Trace("")
+
+	return &StatusActionResult{http.StatusUnauthorized, message}
+}
+
+// Generates a 403 status action result
+func ForbiddenResult(message string) ActionResultInterface {
+	logger.Trace("")
+
+	return &StatusActionResult{http.StatusForbidden, message}
+}
+
+// Generates a 409 status action result
+func ConflictResult(message string) ActionResultInterface {
+	logger.Trace("")
+
+	return &StatusActionResult{http.StatusConflict, message}
+}
+func (result *StatusActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	view := mvcI.statusViews[result.Code]
+	if view != nil {
+		response.WriteHeader(result.Code)
+		ShowView(view.C, view.A, result).Response(mvcI, view.C, view.A, response, request)
+		return
+	}
+
+	message := result.Message
+	if message == "" && result.Code >= 400 {
+		message = http.StatusText(result.Code)
+	}
+
+	if message == "" {
+		response.WriteHeader(result.Code)
+		return
+	}
+
+	http.Error(response, message, result.Code)
+}
+
+// Action result that sends 204 without content
+type NoContentActionResult struct {
+}
+
+// Generates a no-content action result
+func NoContentResult() ActionResultInterface {
+	logger.Trace("")
+
+	return &NoContentActionResult{}
+}
+func (result *NoContentActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// Action result that sends 201 with the Location header and the body in json, if any
+type CreatedActionResult struct {
+	Location string
+	Body     interface{}
+}
+
+// Generates a created action result
+func CreatedResult(location string, body interface{}) ActionResultInterface {
+	logger.Trace("")
+	logger.Debugf("location: %s", location)
+
+	return &CreatedActionResult{location, body}
+}
+func (result *CreatedActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	if result.Body == nil {
+		response.Header().Set("Location", result.Location)
+		response.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	bytes, err := json.Marshal(result.Body)
+	if err != nil {
+		ErrorResult(err).Response(mvcI, c, a, response, request)
+		return
+	}
+
+	response.Header().Set("Location", result.Location)
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(http.StatusCreated)
+	response.Write(bytes)
+}
+
+// Action result that sends the text content
+type ContentActionResult struct {
+	Content     string
+	ContentType string // "text/plain; charset=utf-8" if empty
+}
+
+// Generates a content action result
+func ContentResult(content string, contentType string) ActionResultInterface {
+	logger.Trace("")
+
+	return &ContentActionResult{content, contentType}
+}
+func (result *ContentActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	contentType := result.ContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+
+	response.Header().Set("Content-Type", contentType)
+	response.Write([]byte(result.Content))
+}
+
 // Action result that performs a redirect to another controller/action
 type RedirectToActionResult struct {
 	c      Controller
 	a      Action
 	params map[string]string
+	code   int // redirect status code
 }
 
-// Creates a redirect action result
+// Creates a redirect action result (302). The url is built by MvcInfrastructure.URLFor, so
+// params fill route segments first and the rest goes to the query string.
 func RedirectToAction(c Controller, a Action, params map[string]string) ActionResultInterface {
+	return redirectToAction(c, a, params, http.StatusFound)
+}
+
+// Creates a permanent redirect action result (301), see RedirectToAction
+func RedirectToActionPermanent(c Controller, a Action, params map[string]string) ActionResultInterface {
+	return redirectToAction(c, a, params, http.StatusMovedPermanently)
+}
+
+// Creates a redirect action result preserving the request method and body (307), see RedirectToAction
+func RedirectToActionPreserveMethod(c Controller, a Action, params map[string]string) ActionResultInterface {
+	return redirectToAction(c, a, params, http.StatusTemporaryRedirect)
+}
+
+// Creates a permanent redirect action result preserving the request method and body (308),
+// see RedirectToAction
+func RedirectToActionPermanentPreserveMethod(c Controller, a Action, params map[string]string) ActionResultInterface {
+	return redirectToAction(c, a, params, http.StatusPermanentRedirect)
+}
+
+func redirectToAction(c Controller, a Action, params map[string]string, code int) ActionResultInterface {
 	logger.Trace("")
 
 	//c = toLowerC(c)
 	//a = toLowerA(a)
 
-	logger.Debugf("c: %v, a: %v, p: %v", c, a, params)
+	logger.Debugf("c: %v, a: %v, p: %v, code: %v", c, a, params, code)
 
-	return &RedirectToActionResult{c, a, params}
+	return &RedirectToActionResult{c, a, params, code}
 }
 func (result *RedirectToActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
 	logger.Trace("")
@@ -103,8 +327,88 @@ func (result *RedirectToActionResult) Response(mvcI *MvcInfrastructure, c Contro
 		a = result.a
 	}
 
-	response.Header().Set("Location", createURL(c, a, result.params))
-	response.WriteHeader(302)
+	url, err := mvcI.URLFor(c, a, result.params)
+	if err != nil {
+		logger.Errorf("%v", err)
+		ErrorResult(err).Response(mvcI, c, a, response, request)
+		return
+	}
+
+	response.Header().Set("Location", url)
+	response.WriteHeader(result.code)
+}
+
+// Action result that performs a redirect to the url
+type RedirectActionResult struct {
+	URL  string
+	Code int // redirect status code
+}
+
+// Creates a redirect action result (302) to the url
+func RedirectToURL(url string) ActionResultInterface {
+	logger.Trace("")
+	logger.Debugf("url: %s", url)
+
+	return &RedirectActionResult{url, http.StatusFound}
+}
+
+// Creates a permanent redirect action result (301) to the url
+func RedirectToURLPermanent(url string) ActionResultInterface {
+	logger.Trace("")
+	logger.Debugf("url: %s", url)
+
+	return &RedirectActionResult{url, http.StatusMovedPermanently}
+}
+func (result *RedirectActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	response.Header().Set("Location", result.URL)
+	response.WriteHeader(result.Code)
+}
+
+// Action result that performs a redirect (302) to the url of this site only. Answers 400 for
+// other urls (see IsLocalURL), so it's safe to redirect to user-supplied urls like returnUrl.
+type LocalRedirectActionResult struct {
+	URL string
+}
+
+// Creates a local redirect action result
+func LocalRedirect(url string) ActionResultInterface {
+	logger.Trace("")
+	logger.Debugf("url: %s", url)
+
+	return &LocalRedirectActionResult{url}
+}
+func (result *LocalRedirectActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	if !IsLocalURL(result.URL) {
+		logger.Errorf("not local url: %s", result.URL)
+		BadRequestResult("Redirect url is not local").Response(mvcI, c, a, response, request)
+		return
+	}
+
+	RedirectToURL(result.URL).Response(mvcI, c, a, response, request)
+}
+
+// IsLocalURL returns true if the url is an absolute path of this site: "/account", but
+// not "//evil.com", "/\evil.com" or "http://evil.com".
+func IsLocalURL(url string) bool {
+	if url == "" || url[0] != '/' {
+		return false
+	}
+
+	if len(url) > 1 && (url[1] == '/' || url[1] == '\\') {
+		return false
+	}
+
+	for _, r := range url {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+
+	return true
 }
 
 // ShowViewResult generates http-response using the view associated with the
@@ -113,6 +417,8 @@ type ShowViewResult struct {
 	c  Controller
 	a  Action
 	vm interface{}
+
+	modelState *ModelState // field errors available to the view, see ShowViewWithModelState
 }
 
 // Creates a ShowViewResult using the specified controller, action, vm. See the description of
@@ -125,7 +431,7 @@ func ShowView(c Controller, a Action, vm interface{}) ActionResultInterface {
 
 	logger.Debugf("c: %v, a: %v", c, a)
 
-	return &ShowViewResult{c, a, vm}
+	return &ShowViewResult{c, a, vm, nil}
 }
 func (result *ShowViewResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
 	logger.Tracef("View: res.c = %v, res.a = %v, c = %v, a = %v", result.c, result.a, c, a)
@@ -136,24 +442,16 @@ func (result *ShowViewResult) Response(mvcI *MvcInfrastructure, c Controller, a
 		a = result.a
 	}
 
-	logger.Trace("get views")
-	actions, exists := mvcI.views[c]
-	if !exists {
-		logger.Errorf("Controller not found: %v", c)
-		viewErrorResult(errors.New("Controller not found")).Response(mvcI, c, a, response, request)
-		return
-	}
-
-	logger.Trace("get actions")
-	template, exists := actions[a]
-	if !exists {
-		logger.Errorf("Action not found: %v", a)
-		viewErrorResult(errors.New("Action not found")).Response(mvcI, c, a, response, request)
+	logger.Trace("get view")
+	template, err := mvcI.getView(c, a)
+	if err != nil {
+		logger.Errorf("%v: %v/%v", err, c, a)
+		viewErrorResult(err).Response(mvcI, c, a, response, request)
 		return
 	}
 
 	logger.Trace("render")
-	html, err := renderPage(result.vm, template)
+	html, err := renderPage(result.vm, template, result.modelStateFuncs())
 	if err != nil {
 		logger.Errorf("%v", err)
 		viewErrorResult(err).Response(mvcI, c, a, response, request)
@@ -162,6 +460,18 @@ func (result *ShowViewResult) Response(mvcI *MvcInfrastructure, c Controller, a
 	response.Write(html)
 }
 
+// modelStateFuncs returns view funcs giving access to the model state errors
+func (result *ShowViewResult) modelStateFuncs() template.FuncMap {
+	if result.modelState == nil {
+		return nil
+	}
+
+	return template.FuncMap{
+		"hasErrors":   func() bool { return !result.modelState.IsValid() },
+		"fieldErrors": result.modelState.FieldErrors,
+	}
+}
+
 // Action result that sends JSON-formatted content to the response.
 type JsonActionResult struct {
 	Data interface{}
@@ -184,4 +494,68 @@ func (result *JsonActionResult) Response(mvcI *MvcInfrastructure, c Controller,
 
 	response.Header().Set("Content-Type", "application/json")
 	response.Write(bytes)
-}
\ No newline at end of file
+}
+
+// xmlContentType is the Content-Type of the xml responses
+const xmlContentType = "application/xml; charset=utf-8"
+
+// Action result that sends XML-formatted content to the response.
+type XmlActionResult struct {
+	Data        interface{}
+	Declaration bool   // write the <?xml ... ?> declaration before the data
+	Root        string // name of the root element, by default XMLName of the data or its type name
+}
+
+// Creates a xml action result with the declaration
+func XmlResult(data interface{}) *XmlActionResult {
+	logger.Trace("")
+
+	return &XmlActionResult{data, true, ""}
+}
+
+// RootElement sets the name of the root element. Returns self (for chaining).
+func (result *XmlActionResult) RootElement(name string) *XmlActionResult {
+	result.Root = name
+	return result
+}
+
+// OmitDeclaration turns off the <?xml ... ?> declaration. Returns self (for chaining).
+func (result *XmlActionResult) OmitDeclaration() *XmlActionResult {
+	result.Declaration = false
+	return result
+}
+
+func (result *XmlActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	data, err := result.encode()
+	if err != nil {
+		logger.Errorf("%v", err)
+		ErrorResult(err).Response(mvcI, c, a, response, request)
+		return
+	}
+
+	response.Header().Set("Content-Type", xmlContentType)
+	response.Write(data)
+}
+
+// encode returns the xml of the data
+func (result *XmlActionResult) encode() ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	if result.Declaration {
+		buffer.WriteString(xml.Header)
+	}
+
+	encoder := xml.NewEncoder(buffer)
+	var err error
+	if result.Root != "" {
+		err = encoder.EncodeElement(result.Data, xml.StartElement{Name: xml.Name{Local: result.Root}})
+	} else {
+		err = encoder.Encode(result.Data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -6,11 +6,17 @@ import (
 
 // Contains action information that is used during controller registration, including
 // supported request type (GET/POST/...). 
-// Note that if action for specific http-method is not found then action for GET method will be used.
+// Note that if action for specific http-method is not found then the 405 status code is returned
+// (or action for GET method is used, see MvcInfrastructure.SetGetFallback).
 type ActionInfo struct {
 	handler reflect.Value
 	method  string // http-method
 	action  Action
+	route   string   // route template, empty for the default "/controller/action" url
+	params  []string // names of the scalar parameters, see Params
+
+	maxUploadSize int64        // max size of the form request body, see MaxUploadSize
+	normalizers   []Normalizer // value normalizers, see Normalizers
 }
 
 // NewActionInfo constructs a new ActionInfo using a given func handler. Handler's
@@ -42,3 +48,42 @@ func (info *ActionInfo) Action(a Action) *ActionInfo {
 	info.action = a
 	return info
 }
+
+// Route sets the route template for the action. Template can contain named segments with
+// optional constraints in Gorilla mux syntax: "{id}", "{id:[0-9]+}".
+// If template starts with "/" it is used as is, otherwise it is appended to the
+// default "/controller/action/" url, so Route("{id:[0-9]+}") for the "show" action of the
+// "product" controller gives "/product/show/{id:[0-9]+}".
+// Segment values are passed to the action the same way as query values.
+// Returns self (for chaining).
+func (info *ActionInfo) Route(route string) *ActionInfo {
+	info.route = route
+	return info
+}
+
+// Params sets the names of the scalar action parameters (numbers, strings, bools, time.Time,
+// slices and pointers of them) in order of declaration. Named parameters are bound from route,
+// form and query values like struct fields, conversion errors go to the ModelState:
+//
+//	info.ActionInfos["Show"].Route("{id:[0-9]+}").Params("id", "page") // Show(id int, page *int)
+//
+// Returns self (for chaining).
+func (info *ActionInfo) Params(names ...string) *ActionInfo {
+	info.params = names
+	return info
+}
+
+// MaxUploadSize sets the max size of form and multipart request bodies of the action,
+// see MvcInfrastructure.SetMaxUploadSize. Returns self (for chaining).
+func (info *ActionInfo) MaxUploadSize(size int64) *ActionInfo {
+	info.maxUploadSize = size
+	return info
+}
+
+// Normalizers sets the normalizers of the action values instead of the ones added by
+// MvcInfrastructure.AddNormalizer. Normalizers() without arguments turns normalization off
+// for the action. Returns self (for chaining).
+func (info *ActionInfo) Normalizers(normalizers ...Normalizer) *ActionInfo {
+	info.normalizers = append(make([]Normalizer, 0), normalizers...)
+	return info
+}
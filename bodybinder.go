@@ -0,0 +1,148 @@
+package trinity
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+const (
+	defaultMaxBodySize = 10 << 20 // 10 MB
+
+	urlencodedMediaType = "application/x-www-form-urlencoded"
+	multipartMediaType  = "multipart/form-data"
+)
+
+var (
+	errBodyTooLarge         = errors.New("Request body too large")
+	errUnsupportedMediaType = errors.New("Unsupported media type")
+
+	// bodyBinders decode request bodies by media type
+	bodyBinders = map[string]bodyBinder{
+		"application/json": bindJson,
+		"text/json":        bindJson,
+		"application/xml":  bindXml,
+		"text/xml":         bindXml,
+	}
+
+	// formMediaTypes are bound from request.Form
+	formMediaTypes = map[string]bool{
+		urlencodedMediaType: true,
+		multipartMediaType:  true,
+	}
+
+	// formMethods are the http-methods whose form bodies are parsed
+	formMethods = map[method]bool{
+		Post:   true,
+		Put:    true,
+		Patch:  true,
+		Delete: true,
+	}
+)
+
+// bodyBinder decodes a request body into the action argument
+type bodyBinder func(body io.Reader, input interface{}) error
+
+func bindJson(body io.Reader, input interface{}) error {
+	return json.NewDecoder(body).Decode(input)
+}
+
+func bindXml(body io.Reader, input interface{}) error {
+	return xml.NewDecoder(body).Decode(input)
+}
+
+// SetMaxBodySize sets the max size of json and xml request bodies. Larger requests get
+// the 413 status code.
+func (mvcI *MvcInfrastructure) SetMaxBodySize(size int64) {
+	mvcI.maxBodySize = size
+}
+
+// requestBodyBinder returns the binder for the request Content-Type. Returns nil for
+// requests without body, without Content-Type and for form data.
+// Returns errUnsupportedMediaType if there is no binder for the Content-Type, the error is reported
+// only to actions with arguments created from values.
+func requestBodyBinder(request *http.Request) (bodyBinder, error) {
+	contentType := request.Header.Get("Content-Type")
+	if contentType == "" || request.Body == nil || request.ContentLength == 0 {
+		return nil, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errUnsupportedMediaType
+	}
+
+	if formMediaTypes[mediaType] {
+		return nil, nil
+	}
+
+	binder, exists := bodyBinders[mediaType]
+	if !exists {
+		return nil, errUnsupportedMediaType
+	}
+
+	return binder, nil
+}
+
+// requestFormMediaType returns the media type of the request form body or an empty string if
+// the request has no form body
+func requestFormMediaType(request *http.Request) string {
+	if !formMethods[method(request.Method)] {
+		return ""
+	}
+
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil || !formMediaTypes[mediaType] {
+		return ""
+	}
+
+	return mediaType
+}
+
+// bodyErrorResult returns the result for errors of the body binding
+func bodyErrorResult(err error) ActionResultInterface {
+	switch err {
+	case errBodyTooLarge:
+		return &StatusActionResult{http.StatusRequestEntityTooLarge, err.Error()}
+	case errUnsupportedMediaType:
+		return &StatusActionResult{http.StatusUnsupportedMediaType, err.Error()}
+	}
+
+	return BadRequestResult("Malformed request body: " + err.Error())
+}
+
+// limitedBody reads at most limit bytes of the body and then fails with errBodyTooLarge
+type limitedBody struct {
+	body  io.Reader
+	limit int64
+}
+
+func newLimitedBody(body io.Reader, limit int64) *limitedBody {
+	return &limitedBody{body, limit}
+}
+
+func (limited *limitedBody) Read(p []byte) (int, error) {
+	if limited.limit < 0 {
+		return 0, errBodyTooLarge
+	}
+
+	if int64(len(p)) > limited.limit+1 {
+		p = p[:limited.limit+1]
+	}
+
+	n, err := limited.body.Read(p)
+	limited.limit -= int64(n)
+	if limited.limit < 0 {
+		return 0, errBodyTooLarge
+	}
+
+	return n, err
+}
+
+// exceeded returns true if the body is larger than the limit
+func (limited *limitedBody) exceeded() bool {
+	return limited.limit < 0
+}
@@ -14,6 +14,32 @@ type ControllerInterface interface {
 	GetInfo() ControllerInfoInterface
 }
 
+// ActionExecutingInterface can be implemented by controllers to run common code before every action
+// (load the current user, prepare the view model base, etc.). Controller, action, request and
+// response are already set.
+//
+// Returns nil to continue and call the action.
+//
+// Returns ActionResult to use it as the response, the action is not called.
+type ActionExecutingInterface interface {
+	OnActionExecuting(c Controller, a Action) ActionResultInterface
+}
+
+// ActionExecutedInterface can be implemented by controllers to run common code after every action.
+// Gets the action result (or the OnActionExecuting result) and returns the result used
+// to response: the same one, modified or replaced.
+type ActionExecutedInterface interface {
+	OnActionExecuted(c Controller, a Action, result ActionResultInterface) ActionResultInterface
+}
+
+// ExceptionHandlerInterface can be implemented by controllers to handle panics raised by
+// their actions or OnActionExecuting/OnActionExecuted.
+//
+// Returns ActionResult to response. If nil is returned then ErrorResult(err) is used.
+type ExceptionHandlerInterface interface {
+	OnException(c Controller, a Action, err interface{}) ActionResultInterface
+}
+
 type BaseController struct {
 	C Controller
 	A Action
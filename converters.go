@@ -0,0 +1,255 @@
+package trinity
+
+import (
+	"code.google.com/p/gorilla/schema"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// ConverterTag sets the name of the converter registered by RegisterNamedConverter for
+// the input field. The field can be of the converted type, a pointer or a slice of it:
+//
+//	type OrderInput struct {
+//		Status OrderStatus `convert:"status"`
+//		Total  Money       `convert:"money" query:"total"`
+//	}
+const ConverterTag = "convert"
+
+// timeLayouts are the layouts of time.Time values, tried in order
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// Converter converts a request value to a value of the registered type. Returns an error if
+// the value can't be converted, the error goes to the ModelState.
+type Converter func(value string) (interface{}, error)
+
+// valueDecoder fills action arguments from request values
+type valueDecoder struct {
+	schema     *schema.Decoder
+	types      map[reflect.Type]Converter // converters by type, see RegisterConverter
+	converters map[string]Converter       // named converters, see ConverterTag
+}
+
+// newValueDecoder creates the decoder with time.Time converter. Values that don't match
+// any field (like "Controller" and "Action") are not errors.
+func newValueDecoder() *valueDecoder {
+	decoder := new(valueDecoder)
+
+	decoder.schema = schema.NewDecoder()
+	decoder.schema.IgnoreUnknownKeys(true)
+	decoder.types = make(map[reflect.Type]Converter, 0)
+	decoder.converters = make(map[string]Converter, 0)
+
+	decoder.registerConverter(reflect.TypeOf(time.Time{}), convertTime)
+
+	return decoder
+}
+
+// decode fills input from values. Conversion errors are returned as schema.MultiError.
+func (decoder *valueDecoder) decode(input interface{}, values url.Values) error {
+	return decoder.schema.Decode(input, values)
+}
+
+// registerConverter registers the converter of the type. Top level input fields and named parameters
+// are converted by bindConvertedFields, the schema decoder uses it for nested fields.
+func (decoder *valueDecoder) registerConverter(valueType reflect.Type, converter Converter) {
+	decoder.types[valueType] = converter
+	decoder.schema.RegisterConverter(reflect.Zero(valueType).Interface(), func(value string) reflect.Value {
+		converted, err := convertValue(converter, valueType, value)
+		if err != nil {
+			logger.Debugf("convert %v: %v", valueType, err)
+			return reflect.Value{}
+		}
+		return converted
+	})
+}
+
+// typeConverter returns the converter of the type, a pointer or a slice of it or nil
+func (decoder *valueDecoder) typeConverter(valueType reflect.Type) Converter {
+	if valueType.Kind() == reflect.Ptr || valueType.Kind() == reflect.Slice {
+		valueType = valueType.Elem()
+	}
+
+	return decoder.types[valueType]
+}
+
+// RegisterConverter registers the converter of request values to the type. It's used for input
+// fields, named parameters and their slices and pointers, whatever source the values come from.
+// Register converters before BindController:
+//
+//	mvcI.RegisterConverter(reflect.TypeOf(Money{}), func(value string) (interface{}, error) {
+//		return ParseMoney(value)
+//	})
+func (mvcI *MvcInfrastructure) RegisterConverter(valueType reflect.Type, converter Converter) {
+	if valueType.Kind() == reflect.Interface {
+		panic(fmt.Sprintf("Converter can't be registered for interface %v", valueType))
+	}
+
+	logger.Debugf("converter: %v", valueType)
+
+	mvcI.decoder.registerConverter(valueType, converter)
+}
+
+// RegisterNamedConverter registers the converter used for the input fields with the name in
+// ConverterTag, it takes precedence over the converter of the field type.
+// Register named converters before BindController, unknown names in ConverterTag panic there.
+func (mvcI *MvcInfrastructure) RegisterNamedConverter(name string, converter Converter) {
+	logger.Debugf("named converter: %s", name)
+
+	mvcI.decoder.converters[name] = converter
+}
+
+// convertValue calls the converter and checks that the result is of the type
+func convertValue(converter Converter, valueType reflect.Type, value string) (reflect.Value, error) {
+	result, err := converter(value)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	converted := reflect.ValueOf(result)
+	if !converted.IsValid() || !converted.Type().ConvertibleTo(valueType) {
+		return reflect.Value{}, errors.New(fmt.Sprintf("Converter returned %T instead of %v", result, valueType))
+	}
+
+	return converted.Convert(valueType), nil
+}
+
+// convertTime converts a value to time.Time trying timeLayouts
+func convertTime(value string) (interface{}, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return nil, err
+}
+
+// convertedField is a field of the action input filled by a converter: the field with the
+// ConverterTag or of the type with a registered converter
+type convertedField struct {
+	index     int
+	converter Converter
+	name      string       // value name, see fieldName
+	source    *taggedField // source of the field if it's tagged, nil for untagged sources
+}
+
+// checkConverterTags checks that converters named in ConverterTag of the input structs are registered
+func (decoder *valueDecoder) checkConverterTags(inTypes []reflect.Type) error {
+	for _, inType := range inTypes {
+		if inType.Kind() == reflect.Ptr {
+			inType = inType.Elem()
+		}
+		if inType.Kind() != reflect.Struct {
+			continue
+		}
+
+		for i := 0; i < inType.NumField(); i++ {
+			field := inType.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			name := field.Tag.Get(ConverterTag)
+			if _, exists := decoder.converters[name]; name != "" && !exists {
+				return errors.New(fmt.Sprintf("Converter is not registered: %s", name))
+			}
+		}
+	}
+
+	return nil
+}
+
+// getConvertedFields returns the fields of the struct filled by converters
+func (decoder *valueDecoder) getConvertedFields(structType reflect.Type) []*convertedField {
+	result := make([]*convertedField, 0)
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		converter := decoder.typeConverter(field.Type)
+		if name := field.Tag.Get(ConverterTag); name != "" {
+			var exists bool
+			converter, exists = decoder.converters[name]
+			if !exists {
+				panic(fmt.Sprintf("Converter is not registered: %s", name))
+			}
+		}
+
+		if converter != nil {
+			result = append(result, &convertedField{i, converter, fieldName(field), newTaggedField(field)})
+		}
+	}
+
+	return result
+}
+
+// bindConvertedFields fills the fields of the struct by their converters
+func (invoker *handlerInvoker) bindConvertedFields(input reflect.Value, convertedFields []*convertedField) {
+	if len(convertedFields) == 0 {
+		return
+	}
+
+	values := invoker.mergedValues(nil)
+	for _, field := range convertedFields {
+		var vals []string
+		if field.source != nil {
+			vals = invoker.sourceValues(field.source)
+		} else {
+			vals = findValues(values, field.name)
+		}
+
+		if len(vals) == 0 {
+			continue
+		}
+
+		err := setConverted(input.Elem().Field(field.index), field.converter, vals)
+		if err != nil {
+			logger.Warnf("convert %s: %v", field.name, err)
+			invoker.modelState.AddError(field.name, err.Error())
+		}
+	}
+}
+
+// setConverted sets the value of the converted type, a pointer or a slice of it
+func setConverted(value reflect.Value, converter Converter, vals []string) error {
+	valueType := value.Type()
+
+	switch valueType.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(valueType, len(vals), len(vals))
+		for i, val := range vals {
+			converted, err := convertValue(converter, valueType.Elem(), val)
+			if err != nil {
+				return err
+			}
+			slice.Index(i).Set(converted)
+		}
+		value.Set(slice)
+		return nil
+	case reflect.Ptr:
+		converted, err := convertValue(converter, valueType.Elem(), vals[len(vals)-1])
+		if err != nil {
+			return err
+		}
+		ptr := reflect.New(valueType.Elem())
+		ptr.Elem().Set(converted)
+		value.Set(ptr)
+		return nil
+	}
+
+	converted, err := convertValue(converter, valueType, vals[len(vals)-1])
+	if err != nil {
+		return err
+	}
+	value.Set(converted)
+	return nil
+}
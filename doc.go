@@ -10,6 +10,29 @@ Routing
 
 2. Manual: BindUrl method could be used to bind any specific URL to a specified controller/action.
 
+3. Route parameters: ActionInfo.Route adds named segments to the action URL:
+
+	myControllerInfo.ActionInfos["Show"].Route("{id:[0-9]+}") // "/my/show/{id:[0-9]+}"
+
+Segment values are bound to action arguments like query values and take precedence over them.
+Scalar action parameters can be bound by name with ActionInfo.Params:
+
+	myControllerInfo.ActionInfos["Show"].Route("{id:[0-9]+}").Params("id") // Show(id int)
+
+Requests with http-methods the action has no handler for get the 405 status code with the Allow
+header (SetMethodNotAllowedView sets the view for it). SetGetFallback(true) restores the old
+behaviour of serving them by the GET handler. Actions can share a route with different
+http-methods, the Allow header lists the methods of all of them.
+
+HEAD requests are answered by the GET handler without the body, OPTIONS requests are
+answered with the Allow header (SetPreflightHandler can be used to handle CORS preflight requests),
+unless the action has its own handlers for them. OPTIONS requests are answered without
+the access check.
+
+URLFor builds the url of a controller/action pair from the registered routes (including
+the ones bound by BindUrl), filling route segments from params and escaping the query string.
+Pairs without registered routes get the default "/controller/action" url.
+
 Controllers
 
 Controller is a type which implements ControllerInterface. It is
@@ -17,7 +40,17 @@ registered using a controller bind call with constructor:
 
 mvcI.BindController(NewMyController)
 
-BindController expects parameterless constructor of ControllerInterface which provides the necessary information.
+BindController expects a constructor of ControllerInterface which provides the necessary information.
+Constructor parameters are services resolved by type, they must be registered before binding:
+
+	mvcI.RegisterSingletonAs((*Storage)(nil), storage) // shared by all requests
+	mvcI.RegisterFactory(NewCurrentUser)               // func(*http.Request) *CurrentUser, created per request
+	mvcI.BindController(NewMyController)               // func(Storage, *CurrentUser) *MyController
+
+Actions can declare parameters of the registered service types as well, e.g.
+func (myController *MyController) Save(user *CurrentUser, tx *sql.Tx). Services created per
+request can be disposed by funcs registered with RegisterCleanup, they are called after the
+result has been written.
 
 To simplify controller declaration helpers can be used: BaseController and ToLowerControllerInfoExtracter.
 Example:
@@ -75,6 +108,91 @@ If needed, action with any signature can be added manually using AddAction metho
 		response.Write([]byte("<html><body>Hello!</body></html>"))
 	}
 
+Filters
+
+Filters wrap the action execution and can short-circuit it with their own result or
+post-process the action result. They are added by AddFilter (all actions), AddControllerFilter
+and AddActionFilter, see FilterInterface.
+
+Binding sources
+
+Fields of action arguments are filled by name from route, form and query values. A struct tag
+selects the source of a field instead: route, query, form, header, cookie, or body for the
+field getting the decoded request body, see RouteSource:
+
+	type ShowInput struct {
+		ID    int    `route:"id"`
+		Token string `header:"X-Token"`
+	}
+
+Values are converted to numbers, strings, bools and time.Time. Converters of other types
+are registered by RegisterConverter, RegisterNamedConverter registers a converter selected
+by the field tag, see ConverterTag. Converters are registered before BindController,
+which panics on unknown converter names.
+
+Values are bound as they come. Normalizers (TrimSpace, StripQuotes, NormalizeUnicode or custom
+ones) can be added by AddNormalizer for all actions or set by ActionInfo.Normalizers per action.
+
+Request body
+
+Json and xml request bodies (by Content-Type) are decoded into the action argument which
+is created from request values, after the values. Malformed bodies get the 400 status code,
+unsupported Content-Types get 415 (unless the action has no such argument and reads the body
+itself), bodies larger than SetMaxBodySize get 413.
+
+Urlencoded and multipart form bodies of POST, PUT, PATCH and DELETE requests are bound like
+query values, malformed forms get 400.
+
+Uploaded files fill *multipart.FileHeader and []*multipart.FileHeader fields of action arguments.
+Form bodies larger than SetMaxUploadSize (or ActionInfo.MaxUploadSize of the action) get 413.
+An action with a *multipart.Reader parameter gets the multipart body as a stream instead,
+it is not parsed or buffered.
+
+Model state
+
+Binding errors and validation failures of action arguments are collected into ModelState,
+an action gets it by declaring a *ModelState parameter. Validation rules are set with
+struct tags, see ValidateTag and RegexpTag:
+
+	type SaveInput struct {
+		Name  string `validate:"required,max=20"`
+		Email string `validate:"email"`
+	}
+
+Invalid input can be shown again with ShowViewWithModelState or returned with
+ModelStateJsonResult as a 400 json document.
+
+Results
+
+Actions return ActionResultInterface: ShowView, JsonResult, XmlResult, RedirectToAction, NotFoundResult,
+ErrorResult. FileResult, StreamResult and BytesResult send files, Attachment makes them downloads:
+
+	return mvc.FileResult(path).Attachment("report.pdf")
+
+StatusResult, NoContentResult, CreatedResult, ContentResult and BadRequestResult, UnauthorizedResult,
+ForbiddenResult, ConflictResult answer REST requests; SetStatusView sets the view shown for a status code.
+
+RedirectToAction has permanent (301, 308) and method-preserving (307) variants, RedirectToURL redirects
+to any url. Use LocalRedirect for user-supplied urls (like returnUrl), it refuses off-site ones.
+
+WithStatus, WithHeader and WithCookie add the status code, headers and cookies to the response
+of any result. WithStatus replaces only the 200 status, other statuses of the result are kept:
+
+	return mvc.WithHeader(mvc.JsonResult(data), "Cache-Control", "no-cache")
+
+Content negotiation
+
+NegotiatedResult(vm) shows the controller/action view for browsers and writes the vm as json or
+xml for API clients, depending on the Accept header, the "format" query parameter or the url
+extension. More media types can be added with AddFormatter. Xml is written for named struct vms
+only, maps and anonymous structs get the next acceptable format (or 406).
+
+Controller hooks
+
+A controller can implement ActionExecutingInterface, ActionExecutedInterface and
+ExceptionHandlerInterface to run common code before and after its actions and to
+handle panics raised in them.
+
 Views 
 
 Views are implemented using html/template. Views are registered by ParseViewsFolder,
@@ -82,6 +200,14 @@ which takes views folder path as an argument.
 
 Any file with its extension listed in ViewsSuffix can be used as a view.
 
+Views can use built-in template funcs (url, static, date, number, json, safeHTML, ...) and
+funcs registered by AddTemplateFunc before ParseViewsFolder, see AddTemplateFunc.
+
+Views are compiled once by ParseViewsFolder and cached. Use ReloadViews to
+rebuild the cache after view files were changed. In development WatchViews can be
+used to recompile changed views (and views that use changed master pages or additional
+templates) automatically; StopWatchingViews turns it off.
+
 View folder structure:
 	Controller1
 		->  Action1.ghtml
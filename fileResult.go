@@ -0,0 +1,180 @@
+package trinity
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Action result that sends a file, a stream or bytes. Seekable content is sent by http.ServeContent,
+// so Range and If-Modified-Since requests are supported.
+// Content-Type is detected by the file name extension or by the content.
+type FileActionResult struct {
+	path        string    // path of the file, empty for streams and bytes
+	content     io.Reader // content of the stream or bytes
+	name        string    // file name, used for Content-Disposition and Content-Type detection
+	disposition string    // "inline" or "attachment", Content-Disposition is not sent if empty
+	contentType string
+	modTime     time.Time // sent as Last-Modified if not zero
+}
+
+// Creates a file action result. The file name and modification time are taken from the file.
+// Answers 404 if the file doesn't exist.
+func FileResult(path string) *FileActionResult {
+	logger.Trace("")
+	logger.Debugf("path: %s", path)
+
+	return &FileActionResult{path: path, name: filepath.Base(path)}
+}
+
+// Creates a stream action result. Range requests are supported if the content is io.ReadSeeker.
+// The content is closed after sending if it's io.Closer.
+func StreamResult(content io.Reader) *FileActionResult {
+	logger.Trace("")
+
+	return &FileActionResult{content: content}
+}
+
+// Creates a bytes action result
+func BytesResult(data []byte) *FileActionResult {
+	logger.Trace("")
+
+	return &FileActionResult{content: bytes.NewReader(data)}
+}
+
+// Attachment makes browsers download the content and save it with the name.
+// Returns self (for chaining).
+func (result *FileActionResult) Attachment(name string) *FileActionResult {
+	return result.setDisposition("attachment", name)
+}
+
+// Inline makes browsers show the content, the name is used if it's saved.
+// Returns self (for chaining).
+func (result *FileActionResult) Inline(name string) *FileActionResult {
+	return result.setDisposition("inline", name)
+}
+
+func (result *FileActionResult) setDisposition(disposition string, name string) *FileActionResult {
+	result.disposition = disposition
+	if name != "" {
+		result.name = name
+	}
+	return result
+}
+
+// ContentType sets the Content-Type instead of the detected one. Returns self (for chaining).
+func (result *FileActionResult) ContentType(contentType string) *FileActionResult {
+	result.contentType = contentType
+	return result
+}
+
+// LastModified sets the modification time of the content. Returns self (for chaining).
+func (result *FileActionResult) LastModified(modTime time.Time) *FileActionResult {
+	result.modTime = modTime
+	return result
+}
+
+func (result *FileActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	content := result.content
+	modTime := result.modTime
+	if result.path != "" {
+		file, err := os.Open(result.path)
+		if err != nil {
+			logger.Errorf("%v", err)
+			if os.IsNotExist(err) {
+				NotFoundResult().Response(mvcI, c, a, response, request)
+			} else {
+				ErrorResult(err).Response(mvcI, c, a, response, request)
+			}
+			return
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			logger.Errorf("not a file: %s", result.path)
+			NotFoundResult().Response(mvcI, c, a, response, request)
+			return
+		}
+
+		content = file
+		if modTime.IsZero() {
+			modTime = info.ModTime()
+		}
+	} else if closer, ok := content.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	result.setHeaders(response)
+
+	if seeker, ok := content.(io.ReadSeeker); ok {
+		http.ServeContent(response, request, result.name, modTime, seeker)
+		return
+	}
+
+	logger.Trace("not seekable")
+	reader := bufio.NewReader(content)
+	if response.Header().Get("Content-Type") == "" {
+		head, _ := reader.Peek(512)
+		response.Header().Set("Content-Type", http.DetectContentType(head))
+	}
+	if !modTime.IsZero() {
+		response.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	_, err := io.Copy(response, reader)
+	if err != nil {
+		logger.Errorf("%v", err)
+	}
+}
+
+// setHeaders sets Content-Type and Content-Disposition
+func (result *FileActionResult) setHeaders(response http.ResponseWriter) {
+	contentType := result.contentType
+	if contentType == "" && result.name != "" {
+		contentType = mime.TypeByExtension(filepath.Ext(result.name))
+	}
+	if contentType != "" {
+		response.Header().Set("Content-Type", contentType)
+	}
+
+	if result.disposition != "" {
+		response.Header().Set("Content-Disposition", contentDisposition(result.disposition, result.name))
+	}
+}
+
+// contentDisposition returns the Content-Disposition header value. Non-ASCII names are
+// sent in the filename* parameter (RFC 5987) with an ASCII fallback.
+func contentDisposition(disposition string, name string) string {
+	if name == "" {
+		return disposition
+	}
+
+	fallback := make([]rune, 0, len(name))
+	ascii := true
+	for _, r := range name {
+		switch {
+		case r == '"' || r == '\\':
+			fallback = append(fallback, '_')
+		case r < 0x20 || r > 0x7e:
+			fallback = append(fallback, '_')
+			ascii = false
+		default:
+			fallback = append(fallback, r)
+		}
+	}
+
+	value := disposition + `; filename="` + string(fallback) + `"`
+	if !ascii {
+		value += "; filename*=UTF-8''" + escapePathSegment(name)
+	}
+
+	return value
+}
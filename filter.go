@@ -0,0 +1,89 @@
+package trinity
+
+import (
+	"net/http"
+)
+
+// NextFilter calls the next filter of the chain, or the action if there are no more filters,
+// and returns its result.
+type NextFilter func() ActionResultInterface
+
+// FilterInterface represents objects that wrap the action execution: logging, timing,
+// transactions, authorization, etc.
+//
+// A filter can short-circuit the chain by returning its own ActionResult without calling next,
+// or call next and return the result as is, modified or replaced. The returned result is
+// used to response.
+//
+// Filters are executed in order: global filters, controller filters, action filters. Then
+// the access checker and the action are called.
+type FilterInterface interface {
+	Filter(c Controller, a Action, response http.ResponseWriter, request *http.Request, next NextFilter) ActionResultInterface
+}
+
+// FilterFunc is an adapter to use ordinary funcs as filters.
+type FilterFunc func(c Controller, a Action, response http.ResponseWriter, request *http.Request, next NextFilter) ActionResultInterface
+
+// Filter calls f(c, a, response, request, next).
+func (f FilterFunc) Filter(c Controller, a Action, response http.ResponseWriter, request *http.Request, next NextFilter) ActionResultInterface {
+	return f(c, a, response, request, next)
+}
+
+// AddFilter adds a filter executed for every action.
+func (mvcI *MvcInfrastructure) AddFilter(filter FilterInterface) {
+	logger.Trace("")
+
+	mvcI.filters = append(mvcI.filters, filter)
+}
+
+// AddControllerFilter adds a filter executed for every action of the controller.
+func (mvcI *MvcInfrastructure) AddControllerFilter(c Controller, filter FilterInterface) {
+	logger.Trace("")
+	logger.Debugf("c: %v", c)
+
+	mvcI.controllerFilters[c] = append(mvcI.controllerFilters[c], filter)
+}
+
+// AddActionFilter adds a filter executed for the controller/action pair.
+func (mvcI *MvcInfrastructure) AddActionFilter(c Controller, a Action, filter FilterInterface) {
+	logger.Trace("")
+	logger.Debugf("c: %v, a: %v", c, a)
+
+	actions, exists := mvcI.actionFilters[c]
+	if !exists {
+		actions = make(map[Action][]FilterInterface, 0)
+		mvcI.actionFilters[c] = actions
+	}
+
+	actions[a] = append(actions[a], filter)
+}
+
+// getFilters returns filters of the controller/action pair in the order of execution
+func (mvcI *MvcInfrastructure) getFilters(c Controller, a Action) []FilterInterface {
+	filters := make([]FilterInterface, 0, len(mvcI.filters))
+
+	filters = append(filters, mvcI.filters...)
+	filters = append(filters, mvcI.controllerFilters[c]...)
+	filters = append(filters, mvcI.actionFilters[c][a]...)
+
+	return filters
+}
+
+// runFilters executes the filters chain of the controller/action pair finished by the action call
+func (mvcI *MvcInfrastructure) runFilters(c Controller, a Action, response http.ResponseWriter, request *http.Request, action NextFilter) ActionResultInterface {
+	filters := mvcI.getFilters(c, a)
+
+	var next func(i int) ActionResultInterface
+	next = func(i int) ActionResultInterface {
+		if i == len(filters) {
+			return action()
+		}
+
+		logger.Debugf("filter %d", i)
+		return filters[i].Filter(c, a, response, request, func() ActionResultInterface {
+			return next(i + 1)
+		})
+	}
+
+	return next(0)
+}
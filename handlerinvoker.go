@@ -2,6 +2,8 @@ package trinity
 
 import (
 	"code.google.com/p/gorilla/schema"
+	"io"
+	"mime/multipart"
 	"net/url"
 	"reflect"
 )
@@ -54,35 +56,33 @@ When handler invokers for these method descriptors are called parameters and val
 
 1. Parameters: *MyController, http.ResponseWriter, *http.Request, Controller, Action.
 
-2. Values: ( url.Values ), extracted from URL and form data.
+2. Values: ( url.Values ), extracted from URL, form data and route segments. Scalar arguments
+named by ActionInfo.Params are filled from the values with their names.
+
+3. Body: json or xml request body, if any.
+
+4. Services: arguments of the types registered by RegisterSingleton/RegisterFactory are resolved
+from the services of the request.
 
 For the first method, only parameters are used.
 For the second one, there is an argument (*Action2Input) which is not listed in parameters, so an empty
 Action2Input object would be created and filled using form data and URL. Here the term 'Filled' 
 means that 'Name' field of the created Action2Input struct would be set to a value with the same name
-(URL value or form value "Name").
+(URL value or form value "Name"). If the request has a json/xml body, it is decoded into the
+same object afterwards.
 
 */
 
-var (
-	decoder = schema.NewDecoder()
-)
-
-func decode(input interface{}, values url.Values) {
-	removeQuotesFromStringValues(values)
-	decoder.Decode(input, values)
-}
-
-// removeQuotesFromStringValues is used to remove quotes from strings that are added
-// when javascript strings parameters are passed.
-func removeQuotesFromStringValues(values url.Values) {
-	for _, vals := range values {
-		for i, val := range vals {
-			if len(val) > 1 && val[0] == '"' && val[len(val)-1] == '"' {
-				vals[i] = val[1 : len(val)-1]
-			}
+// addDecodeErrors adds the errors returned by valueDecoder.decode to the model state
+func addDecodeErrors(err error, modelState *ModelState) {
+	if multiError, ok := err.(schema.MultiError); ok {
+		for field, fieldErr := range multiError {
+			modelState.AddError(field, fieldErr.Error())
 		}
+		return
 	}
+
+	modelState.AddError("", err.Error())
 }
 
 // invokerParam stores info needed to call a method with reflect.
@@ -112,9 +112,23 @@ func newInvokerParam(value interface{}) *invokerParam {
 // 1. Predefined invokerParam objects
 // 2. New objects that are extracted from the http request
 type handlerInvoker struct {
-	values  url.Values
-	params  []*invokerParam
-	handler *methodDescriptor
+	values     url.Values
+	sources    map[string]url.Values              // values by source, see RouteSource
+	files      map[string][]*multipart.FileHeader // uploaded files, see bindFiles
+	params     []*invokerParam
+	handler    *methodDescriptor
+	decoder    *valueDecoder // converts values, see SetDecoder
+	modelState *ModelState   // binding and validation errors
+
+	normalizers []Normalizer // applied to values before conversion, see SetNormalizers
+
+	body          io.Reader  // request body, decoded into the first argument created from values
+	bodyBinder    bodyBinder // decodes the body
+	bodyBinderErr error      // the body can't be decoded, becomes bodyErr if an argument is created from values
+	bodyErr       error      // body decoding error, the action is not called if set
+
+	services   *serviceScope // registered services of the request
+	serviceErr error         // service creation error, the action is not called if set
 }
 
 // newHandlerInvoker constructs a handlerInvoker that will be used to invoke 
@@ -124,7 +138,10 @@ func newHandlerInvoker(handler *methodDescriptor) *handlerInvoker {
 
 	invoker.handler = handler
 	invoker.values = make(url.Values, 0)
+	invoker.sources = make(map[string]url.Values, 0)
 	invoker.params = make([]*invokerParam, 0)
+	invoker.modelState = NewModelState()
+	invoker.AddParam(invoker.modelState)
 
 	return invoker
 }
@@ -158,6 +175,95 @@ func (invoker *handlerInvoker) AddValues(values url.Values) *handlerInvoker {
 	return invoker
 }
 
+// AddRouteValues sets values extracted from the route segments as the RouteSource values.
+// The invoker is returned to provide convenient method call chaining.
+func (invoker *handlerInvoker) AddRouteValues(values map[string]string) *handlerInvoker {
+	logger.Trace("")
+
+	routeValues := make(url.Values, len(values))
+	for k, v := range values {
+		logger.Debugf("[%s] %s", k, v)
+		routeValues[k] = []string{v}
+	}
+
+	return invoker.SetSourceValues(RouteSource, routeValues)
+}
+
+// SetSourceValues sets values of the source (RouteSource, QuerySource, etc.). Route, form and query
+// values fill untagged input fields, values of all sources fill tagged fields, see RouteSource.
+// The invoker is returned to provide convenient method call chaining.
+func (invoker *handlerInvoker) SetSourceValues(source string, values url.Values) *handlerInvoker {
+	logger.Trace("")
+	logger.Debugf("source: %s", source)
+
+	invoker.sources[source] = values
+
+	return invoker
+}
+
+// SetDecoder sets the decoder used to fill arguments from values.
+// The invoker is returned to provide convenient method call chaining.
+func (invoker *handlerInvoker) SetDecoder(decoder *valueDecoder) *handlerInvoker {
+	logger.Trace("")
+
+	invoker.decoder = decoder
+
+	return invoker
+}
+
+// SetNormalizers sets the normalizers applied to values before conversion.
+// The invoker is returned to provide convenient method call chaining.
+func (invoker *handlerInvoker) SetNormalizers(normalizers []Normalizer) *handlerInvoker {
+	logger.Trace("")
+
+	invoker.normalizers = normalizers
+
+	return invoker
+}
+
+// SetFiles sets the uploaded files. They fill *multipart.FileHeader and []*multipart.FileHeader
+// input fields. The invoker is returned to provide convenient method call chaining.
+func (invoker *handlerInvoker) SetFiles(files map[string][]*multipart.FileHeader) *handlerInvoker {
+	logger.Trace("")
+
+	invoker.files = files
+
+	return invoker
+}
+
+// SetServices sets the services scope. Arguments of the registered service types are resolved from it.
+// The invoker is returned to provide convenient method call chaining.
+func (invoker *handlerInvoker) SetServices(services *serviceScope) *handlerInvoker {
+	logger.Trace("")
+
+	invoker.services = services
+
+	return invoker
+}
+
+// SetBody sets the request body that would be decoded by the binder into the first argument
+// which is not listed in parameters. The body is decoded after values, so body fields replace values.
+// The invoker is returned to provide convenient method call chaining.
+func (invoker *handlerInvoker) SetBody(body io.Reader, binder bodyBinder) *handlerInvoker {
+	logger.Trace("")
+
+	invoker.body = body
+	invoker.bodyBinder = binder
+
+	return invoker
+}
+
+// SetBodyBinderError sets the error of the body binder lookup (e.g. unsupported Content-Type).
+// It's reported only if an argument is created from values, so actions reading the body themselves
+// get any body. The invoker is returned to provide convenient method call chaining.
+func (invoker *handlerInvoker) SetBodyBinderError(err error) *handlerInvoker {
+	logger.Trace("")
+
+	invoker.bodyBinderErr = err
+
+	return invoker
+}
+
 // AddParam adds a parameter to the invoker. The invoker 
 // is returned to provide convenient method call chaining.
 func (invoker *handlerInvoker) AddParam(param interface{}) *handlerInvoker {
@@ -174,12 +280,27 @@ func (invoker *handlerInvoker) Invoke() ActionResultInterface {
 
 	args := make([]reflect.Value, len(invoker.handler.inTypes))
 	for i, paramType := range invoker.handler.inTypes {
-		paramValue := invoker.getParamValue(paramType)
+		var paramValue reflect.Value
+		if name := invoker.handler.paramName(i); name != "" {
+			paramValue = invoker.getNamedParamValue(name, paramType)
+		} else {
+			paramValue = invoker.getParamValue(paramType)
+		}
 		traceParamValue(paramValue)
 
 		args[i] = paramValue
 	}
 
+	if invoker.serviceErr != nil {
+		logger.Errorf("service: %v", invoker.serviceErr)
+		return ErrorResult(invoker.serviceErr)
+	}
+
+	if invoker.bodyErr != nil {
+		logger.Errorf("body: %v", invoker.bodyErr)
+		return bodyErrorResult(invoker.bodyErr)
+	}
+
 	rets := invoker.handler.value.Call(args)
 	if len(rets) > 0 {
 		if res, ok := rets[0].Interface().(ActionResultInterface); ok {
@@ -230,15 +351,89 @@ func (invoker *handlerInvoker) getParamValue(paramTypeRaw reflect.Type) reflect.
 		}*/
 	}
 
+	if invoker.services != nil && invoker.services.canResolve(paramTypeRaw) {
+		logger.Trace("from services")
+		paramValue, err := invoker.services.resolve(paramTypeRaw)
+		if err != nil {
+			invoker.serviceErr = err
+			return reflect.Zero(paramTypeRaw)
+		}
+		return paramValue
+	}
+
+	if paramTypeRaw == multipartReaderType {
+		logger.Error("multipart reader is not passed")
+		return reflect.Zero(paramTypeRaw)
+	}
+
 	logger.Trace("decode from values")
 	paramValue := getParamNewValue(paramType, isPtr)
 	logger.Debugf("%v", paramValue)
-	decode(paramValue.Interface(), invoker.values)
+	input := paramValue
+	if !isPtr {
+		input = paramValue.Addr()
+	}
+
+	invoker.bindInput(input)
 	logger.Debugf("%v", paramValue)
 
+	validate(paramValue, "", invoker.modelState)
+
 	return paramValue
 }
 
+// bindInput fills the input (a pointer) from values and the request body
+func (invoker *handlerInvoker) bindInput(input reflect.Value) {
+	if invoker.bodyBinderErr != nil && invoker.bodyErr == nil {
+		invoker.bodyErr = invoker.bodyBinderErr
+	}
+
+	taggedFields := make([]*taggedField, 0)
+	convertedFields := make([]*convertedField, 0)
+	bodyField := -1
+	if input.Elem().Kind() == reflect.Struct {
+		taggedFields = invoker.decoder.getTaggedFields(input.Elem().Type())
+		convertedFields = invoker.decoder.getConvertedFields(input.Elem().Type())
+		bodyField = getBodyField(input.Elem().Type())
+	}
+
+	excluded := make([]string, 0, len(taggedFields)+len(convertedFields))
+	for _, field := range taggedFields {
+		excluded = append(excluded, fieldName(field.field))
+	}
+	for _, field := range convertedFields {
+		excluded = append(excluded, field.name)
+	}
+
+	err := invoker.decoder.decode(input.Interface(), invoker.mergedValues(excluded))
+	if err != nil {
+		logger.Warnf("decode: %v", err)
+		addDecodeErrors(err, invoker.modelState)
+	}
+
+	invoker.bindTaggedFields(input, taggedFields)
+	invoker.bindConvertedFields(input, convertedFields)
+	if input.Elem().Kind() == reflect.Struct {
+		invoker.bindFiles(input)
+	}
+
+	if invoker.body == nil {
+		return
+	}
+
+	logger.Trace("decode from body")
+	bodyInput := input
+	if bodyField >= 0 {
+		bodyInput = input.Elem().Field(bodyField).Addr()
+	}
+
+	err = invoker.bodyBinder(invoker.body, bodyInput.Interface())
+	if err != nil && err != io.EOF {
+		invoker.bodyErr = err
+	}
+	invoker.body = nil
+}
+
 func getParamNewValue(paramType reflect.Type, isPtr bool) reflect.Value {
 	if isPtr {
 		return reflect.New(paramType)
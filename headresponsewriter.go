@@ -0,0 +1,62 @@
+package trinity
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	sniffLen = 512 // bytes used by http.DetectContentType
+)
+
+// headResponseWriter is used to answer HEAD requests by GET handlers. It discards the body
+// but keeps the headers and sets Content-Length (and Content-Type, if not set) as if the body was sent.
+type headResponseWriter struct {
+	http.ResponseWriter
+
+	status int
+	length int
+	sniff  []byte // beginning of the body, used to detect Content-Type
+}
+
+func newHeadResponseWriter(response http.ResponseWriter) *headResponseWriter {
+	return &headResponseWriter{ResponseWriter: response}
+}
+
+func (writer *headResponseWriter) WriteHeader(status int) {
+	if writer.status == 0 {
+		writer.status = status
+	}
+}
+
+func (writer *headResponseWriter) Write(data []byte) (int, error) {
+	writer.WriteHeader(http.StatusOK)
+
+	if len(writer.sniff) < sniffLen {
+		rest := sniffLen - len(writer.sniff)
+		if rest > len(data) {
+			rest = len(data)
+		}
+		writer.sniff = append(writer.sniff, data[:rest]...)
+	}
+
+	writer.length += len(data)
+	return len(data), nil
+}
+
+// finish writes the headers to the underlying response writer
+func (writer *headResponseWriter) finish() {
+	writer.WriteHeader(http.StatusOK)
+
+	header := writer.Header()
+	if writer.length > 0 {
+		if header.Get("Content-Length") == "" {
+			header.Set("Content-Length", strconv.Itoa(writer.length))
+		}
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", http.DetectContentType(writer.sniff))
+		}
+	}
+
+	writer.ResponseWriter.WriteHeader(writer.status)
+}
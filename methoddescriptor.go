@@ -6,8 +6,12 @@ import (
 
 // methodDescriptor contains reflect info for method extracted using reflect
 type methodDescriptor struct {
-	value   reflect.Value
-	inTypes []reflect.Type
+	value      reflect.Value
+	inTypes    []reflect.Type
+	paramNames []string // names of the scalar parameters by index of inTypes, see ActionInfo.Params
+
+	maxUploadSize int64        // max size of the form request body, see ActionInfo.MaxUploadSize
+	normalizers   []Normalizer // normalizers of the action values, nil for the global ones
 }
 
 // newMethodDescriptorFromMethod is used to construct a method descriptor using a given method.
@@ -33,3 +37,14 @@ func newMethodDescriptorFromValue(value reflect.Value) *methodDescriptor {
 
 	return descriptor
 }
+
+// hasParam returns true if the method has a parameter of the type
+func (descriptor *methodDescriptor) hasParam(paramType reflect.Type) bool {
+	for _, inType := range descriptor.inTypes {
+		if inType == paramType {
+			return true
+		}
+	}
+
+	return false
+}
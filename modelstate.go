@@ -0,0 +1,106 @@
+package trinity
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// ModelState stores binding and validation errors of the action arguments.
+// Errors are grouped by field name (the name of the form/query value).
+//
+// To get it an action declares a *ModelState parameter:
+//
+//	func (myController *MyController) Save(input *SaveInput, modelState *mvc.ModelState) mvc.ActionResultInterface {
+//		if !modelState.IsValid() {
+//			return mvc.ShowViewWithModelState("", "", input, modelState)
+//		}
+//		...
+//	}
+//
+// Arguments are bound before the action is called, so the state is complete when the action runs.
+type ModelState struct {
+	errors map[string][]string
+}
+
+// NewModelState creates a new valid ModelState
+func NewModelState() *ModelState {
+	modelState := new(ModelState)
+
+	modelState.errors = make(map[string][]string, 0)
+
+	return modelState
+}
+
+// IsValid returns true if there are no errors
+func (modelState *ModelState) IsValid() bool {
+	return len(modelState.errors) == 0
+}
+
+// AddError adds an error message for the field. Use empty field name for errors
+// not related to a specific field.
+func (modelState *ModelState) AddError(field string, message string) {
+	logger.Debugf("[%s] %s", field, message)
+
+	modelState.errors[field] = append(modelState.errors[field], message)
+}
+
+// FieldErrors returns error messages for the field
+func (modelState *ModelState) FieldErrors(field string) []string {
+	return modelState.errors[field]
+}
+
+// Fields returns sorted names of the fields with errors
+func (modelState *ModelState) Fields() []string {
+	fields := make([]string, 0, len(modelState.errors))
+	for field := range modelState.errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	return fields
+}
+
+// Errors returns all error messages grouped by field name
+func (modelState *ModelState) Errors() map[string][]string {
+	return modelState.errors
+}
+
+// ShowViewWithModelState creates a ShowViewResult that re-renders the view with field errors.
+// Besides the vm, the view can use the following funcs:
+//
+//	hasErrors             - true if model state is invalid
+//	fieldErrors "Name"    - error messages of the field
+func ShowViewWithModelState(c Controller, a Action, vm interface{}, modelState *ModelState) ActionResultInterface {
+	logger.Trace("")
+	logger.Debugf("c: %v, a: %v", c, a)
+
+	return &ShowViewResult{c, a, vm, modelState}
+}
+
+// Action result that sends model state errors as a json document with the 400 status code:
+//
+//	{"errors": {"Name": ["is required"]}}
+type ModelStateJsonActionResult struct {
+	ModelState *ModelState
+}
+
+// Creates a model state json action result
+func ModelStateJsonResult(modelState *ModelState) ActionResultInterface {
+	logger.Trace("")
+
+	return &ModelStateJsonActionResult{modelState}
+}
+func (result *ModelStateJsonActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	bytes, err := json.Marshal(map[string]interface{}{"errors": result.ModelState.Errors()})
+	if err != nil {
+		ErrorResult(err).Response(mvcI, c, a, response, request)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(400)
+	response.Write(bytes)
+}
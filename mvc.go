@@ -2,24 +2,47 @@ package trinity
 
 import (
 	"code.google.com/p/gorilla/mux"
+	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
-	"reflect"
+	"sync"
+	"time"
 )
 
 // MvcInfrastructure stores the data needed to create and support the MVC environment.
 // Used to register controllers, actions, views.
 type MvcInfrastructure struct {
-	accessChecker AccessCheckerInterface // used to check access to specific controller/action pairs
-	viewsFolder   string                 // path to the views folder
-
-	notFoundView      *ControllerAction // used to show the url-not-found error
-	internalErrorView *ControllerAction // used to show internal server errors
-
-	handlers    map[Controller]map[Action]map[method]*methodDescriptor // action handlers
-	views       map[Controller]map[Action]*templateDescriptor          // views
-	controllerConstructors map[Controller]reflect.Value // Controller ctors
-
+	accessChecker    AccessCheckerInterface    // used to check access to specific controller/action pairs
+	preflightHandler PreflightHandlerInterface // used to answer OPTIONS requests, see SetPreflightHandler
+	viewsFolder      string                    // path to the views folder
+	staticPrefix     string                    // url prefix of the static files, see ServeStatic
+	templateFuncs    template.FuncMap          // funcs registered by AddTemplateFunc
+	maxBodySize      int64                     // max size of json and xml request bodies
+	maxUploadSize    int64                     // max size of form and multipart request bodies
+	decoder          *valueDecoder             // fills action arguments from values, see RegisterConverter
+	normalizers      []Normalizer              // applied to values of all actions, see AddNormalizer
+	formatters       []*formatterDescriptor    // used by NegotiatedResult, see AddFormatter
+
+	notFoundView         *ControllerAction         // used to show the url-not-found error
+	internalErrorView    *ControllerAction         // used to show internal server errors
+	methodNotAllowedView *ControllerAction         // used to show the method-not-allowed error
+	statusViews          map[int]*ControllerAction // used by StatusActionResult, see SetStatusView
+
+	getFallback bool // serve missing http-methods by the GET handler, see SetGetFallback
+
+	filters           []FilterInterface                           // executed for every action
+	controllerFilters map[Controller][]FilterInterface            // executed for every action of the controller
+	actionFilters     map[Controller]map[Action][]FilterInterface // executed for the controller/action pair
+
+	handlers               map[Controller]map[Action]map[method]*methodDescriptor // action handlers
+	views                  map[Controller]map[Action]*templateDescriptor          // views
+	viewsLock              sync.RWMutex                                           // guards views during reloads
+	viewsWatcher           *viewsWatcher                                          // reloads changed views, nil if watching is off
+	controllerConstructors map[Controller]*methodDescriptor                       // Controller ctors
+	services               *serviceContainer                                      // services injected into controllers
+	routes                 map[Controller]map[Action][]*routeDescriptor           // registered routes, used to build urls
+	urlActions             map[string]map[method]*ControllerAction                // pairs bound to the url templates by bindAction
 
 	Router *mux.Router // The main routing object
 }
@@ -31,7 +54,21 @@ func NewMvcInfrastructure() *MvcInfrastructure {
 	mvcI.handlers = make(map[Controller]map[Action]map[method]*methodDescriptor, 0)
 	mvcI.views = make(map[Controller]map[Action]*templateDescriptor, 0)
 	//mvcI.controllers = make([]ControllerInterface, 0)
-	mvcI.controllerConstructors = make(map[Controller]reflect.Value, 0)
+	mvcI.controllerConstructors = make(map[Controller]*methodDescriptor, 0)
+	mvcI.services = newServiceContainer()
+	mvcI.routes = make(map[Controller]map[Action][]*routeDescriptor, 0)
+	mvcI.urlActions = make(map[string]map[method]*ControllerAction, 0)
+	mvcI.templateFuncs = make(template.FuncMap, 0)
+	mvcI.maxBodySize = defaultMaxBodySize
+	mvcI.maxUploadSize = defaultMaxUploadSize
+	mvcI.decoder = newValueDecoder()
+	mvcI.normalizers = make([]Normalizer, 0)
+	mvcI.statusViews = make(map[int]*ControllerAction, 0)
+	mvcI.formatters = make([]*formatterDescriptor, 0)
+	mvcI.addDefaultFormatters()
+	mvcI.filters = make([]FilterInterface, 0)
+	mvcI.controllerFilters = make(map[Controller][]FilterInterface, 0)
+	mvcI.actionFilters = make(map[Controller]map[Action][]FilterInterface, 0)
 
 	mvcI.Router = mux.NewRouter()
 	mvcI.Router.NotFoundHandler = NewNotFoundHandler(mvcI)
@@ -46,7 +83,12 @@ func (mvcI *MvcInfrastructure) SetAccessChecker(accessChecker AccessCheckerInter
 
 // Если запрашиваемый URL начинается с указанного префикса, то выдает статические файлы находящиеся по указанному пути.
 // Для всех остальных запросов отвечает mvcI.Router.NotFoundHandler
+// Путь к статическим файлам в шаблонах строится функцией "static" от первого зарегистрированного префикса.
 func (mvcI *MvcInfrastructure) ServeStatic(prefix string, path string) {
+	if mvcI.staticPrefix == "" {
+		mvcI.staticPrefix = prefix
+	}
+
 	h := stripPrefix(prefix, http.FileServer(http.Dir(path)), mvcI.Router.NotFoundHandler)
 	mvcI.Router.NewRoute().Handler(h)
 }
@@ -58,16 +100,91 @@ func (mvcI *MvcInfrastructure) ParseViewsFolder(viewsFolder string) error {
 
 	mvcI.viewsFolder = viewsFolder
 
-	err := newViewFolderParser(mvcI).parse()
+	return mvcI.loadViews()
+}
+
+// ReloadViews drops the compiled views cache and parses the views folder again.
+// Requests being served during the reload keep using the old views.
+func (mvcI *MvcInfrastructure) ReloadViews() error {
+	logger.Trace("")
+
+	if mvcI.viewsFolder == "" {
+		return errors.New("Views folder is not set. Call ParseViewsFolder first")
+	}
+
+	return mvcI.loadViews()
+}
+
+// WatchViews starts polling the views folder with the specified interval and recompiles views
+// when view files, master pages or additional templates are changed. New controller folders and
+// action files are picked up too. Intended for development, watching is off by default.
+// Must be called after ParseViewsFolder.
+func (mvcI *MvcInfrastructure) WatchViews(interval time.Duration) error {
+	logger.Trace("")
+
+	if mvcI.viewsFolder == "" {
+		return errors.New("Views folder is not set. Call ParseViewsFolder first")
+	}
+
+	mvcI.StopWatchingViews()
+
+	watcher := newViewsWatcher(mvcI, interval)
+	err := watcher.start()
 	if err != nil {
 		logger.Error(err.Error())
 		return err
 	}
 
+	mvcI.viewsWatcher = watcher
 	return nil
 }
 
-func (mvcI *MvcInfrastructure) bindView(c Controller, a Action, templatePath string) error {
+// StopWatchingViews stops the views polling started by WatchViews. Views stay as they are.
+func (mvcI *MvcInfrastructure) StopWatchingViews() {
+	if mvcI.viewsWatcher == nil {
+		return
+	}
+
+	close(mvcI.viewsWatcher.stop)
+	mvcI.viewsWatcher = nil
+}
+
+// loadViews parses the views folder into a new views map and replaces the current one
+func (mvcI *MvcInfrastructure) loadViews() error {
+	views := make(map[Controller]map[Action]*templateDescriptor, 0)
+
+	err := newViewFolderParser(mvcI, views).parse()
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+
+	mvcI.viewsLock.Lock()
+	mvcI.views = views
+	mvcI.viewsLock.Unlock()
+
+	return nil
+}
+
+// getView returns the compiled view for the controller/action pair
+func (mvcI *MvcInfrastructure) getView(c Controller, a Action) (*templateDescriptor, error) {
+	mvcI.viewsLock.RLock()
+	defer mvcI.viewsLock.RUnlock()
+
+	actions, exists := mvcI.views[c]
+	if !exists {
+		return nil, errors.New("Controller not found")
+	}
+
+	template, exists := actions[a]
+	if !exists {
+		return nil, errors.New("Action not found")
+	}
+
+	return template, nil
+}
+
+func (mvcI *MvcInfrastructure) bindView(views map[Controller]map[Action]*templateDescriptor, c Controller, a Action, templatePath string, funcs template.FuncMap) error {
 	logger.Trace("")
 
 	//c = toLowerC(c)
@@ -75,16 +192,16 @@ func (mvcI *MvcInfrastructure) bindView(c Controller, a Action, templatePath str
 
 	logger.Debugf("c = %v, a = %v", c, a)
 
-	template, err := newTemplateDescriptor(mvcI.viewsFolder, templatePath)
+	template, err := newTemplateDescriptor(mvcI.viewsFolder, templatePath, funcs)
 	if err != nil {
 		logger.Errorf("%v", err)
 		return err
 	}
 
-	actions, exists := mvcI.views[c]
+	actions, exists := views[c]
 	if !exists {
 		actions = make(map[Action]*templateDescriptor, 0)
-		mvcI.views[c] = actions
+		views[c] = actions
 	}
 
 	actions[a] = template
@@ -109,11 +226,41 @@ func (mvcI *MvcInfrastructure) SetInternalErrorView(internalErrorView *Controlle
 	mvcI.internalErrorView = internalErrorView
 }
 
+// SetMethodNotAllowedView sets the view shown when the action has no handler for the request http-method.
+// The view gets MethodNotAllowedActionResult as the vm.
+func (mvcI *MvcInfrastructure) SetMethodNotAllowedView(methodNotAllowedView *ControllerAction) {
+	if methodNotAllowedView != nil && !methodNotAllowedView.IsFull() {
+		panic("MethodNotAllowedView must contains controller and action")
+	}
+
+	mvcI.methodNotAllowedView = methodNotAllowedView
+}
+
+// SetStatusView sets the view shown by StatusActionResult with the status code (BadRequestResult,
+// ForbiddenResult, etc.). The view gets StatusActionResult as the vm. Nil view removes it.
+func (mvcI *MvcInfrastructure) SetStatusView(code int, view *ControllerAction) {
+	if view == nil {
+		delete(mvcI.statusViews, code)
+		return
+	}
+
+	if !view.IsFull() {
+		panic("StatusView must contains controller and action")
+	}
+
+	mvcI.statusViews[code] = view
+}
+
 func defaultNotFound(response http.ResponseWriter, request *http.Request) {
 	logger.Trace("")
 	response.Write([]byte("<html><body>Not found</body></html>"))
 }
 
+func defaultMethodNotAllowed(response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+	response.Write([]byte("<html><body>Method not allowed</body></html>"))
+}
+
 func defaultInternalError(response http.ResponseWriter, request *http.Request, err interface{}) {
 	logger.Debugf("%v", err)
 	response.Write([]byte(fmt.Sprintf("<html><body>Internal Error: %v</body></html>", err)))
@@ -124,11 +271,22 @@ func (mvcI *MvcInfrastructure) wrapHandler(c Controller, a Action) func(response
 	return func(response http.ResponseWriter, request *http.Request) {
 		logger.Debugf("c: %v, a: %v, m: %s", c, a, request.Method)
 
-		mvcI.handleRequest(c, a, response, request)
+		mvcI.handleRequest(c, a, mvcI.handlers[c][a], response, request)
 	}
 }
 
-func (mvcI *MvcInfrastructure) handleRequest(c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+// handleRequest serves the request by the controller/action pair, methods are handlers of the route by http-method
+func (mvcI *MvcInfrastructure) handleRequest(c Controller, a Action, methods map[method]*methodDescriptor, response http.ResponseWriter, request *http.Request) {
+	scope := newServiceScope(mvcI.services, response, request)
+	defer scope.cleanup()
+
+	if method(request.Method) == Head {
+		headResponse := newHeadResponseWriter(response)
+		defer headResponse.finish()
+		response = headResponse
+		scope.response = response
+	}
+
 	defer func() {
 		if err := recover(); err != nil {
 			logger.Trace("recovered from panic")
@@ -136,23 +294,33 @@ func (mvcI *MvcInfrastructure) handleRequest(c Controller, a Action, response ht
 		}
 	}()
 	
-	var res ActionResultInterface
-	
+	res := mvcI.runFilters(c, a, response, request, func() ActionResultInterface {
+		return mvcI.checkAccessAndCallAction(c, a, methods, response, request, scope)
+	})
+
+	if res != nil {
+		res.Response(mvcI, c, a, response, request)
+	}
+
+	logger.Trace("Done")
+}
+
+// checkAccessAndCallAction calls the action if the access checker allows it. OPTIONS requests of actions
+// without OPTIONS handlers are answered before the check: CORS preflight requests carry no credentials.
+func (mvcI *MvcInfrastructure) checkAccessAndCallAction(c Controller, a Action, methods map[method]*methodDescriptor, response http.ResponseWriter, request *http.Request, scope *serviceScope) ActionResultInterface {
+	if _, exists := methods[Options]; methods != nil && !exists && method(request.Method) == Options {
+		logger.Trace("options")
+		return OptionsResult(allowedMethods(methods))
+	}
+
 	if mvcI.accessChecker != nil {
 		logger.Trace("check access")
-		res = mvcI.accessChecker.IsAccessAllowed(c, a, response, request)
+		res := mvcI.accessChecker.IsAccessAllowed(c, a, response, request)
 		if res != nil {
 			logger.Trace("access denied")
+			return res
 		}
 	}
 
-	if res == nil {
-		res = mvcI.callAction(c, a, response, request)
-	}
-	
-	if res != nil {
-		res.Response(mvcI, c, a, response, request)
-	}
-
-	logger.Trace("Done")
+	return mvcI.callAction(c, a, methods, response, request, scope)
 }
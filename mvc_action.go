@@ -1,20 +1,23 @@
 package trinity
 
 import (
+	"code.google.com/p/gorilla/mux"
 	"net/http"
+	"net/url"
+	"sort"
 )
 
 const (
-	defaultMaxMemory = 32 << 20 // 32 MB
+	defaultMaxMemory = 32 << 20 // 32 MB, multipart data above it is stored in temporary files
 )
 
-func (mvcI *MvcInfrastructure) bindAction(c Controller, a Action, m method, handler *methodDescriptor) {
+func (mvcI *MvcInfrastructure) bindAction(c Controller, a Action, m method, routeTemplate string, handler *methodDescriptor) {
 	logger.Trace("")
 
 	//c = toLowerC(c)
 	//a = toLowerA(a)
 
-	logger.Debugf("c: %v, a: %v, m: %v", c, a, m)
+	logger.Debugf("c: %v, a: %v, m: %v, r: %v", c, a, m, routeTemplate)
 
 	mvcI.checkViewOnActionBind(c, a)
 
@@ -34,33 +37,103 @@ func (mvcI *MvcInfrastructure) bindAction(c Controller, a Action, m method, hand
 
 	methods[m] = handler
 
-	url := createURL(c, a, nil)
+	url := createRouteURL(c, a, routeTemplate)
 	logger.Debugf("URL = %s", url)
+
+	// Actions sharing the url are bound with different methods, the fallback route
+	// answers methods that none of them handles (HEAD, OPTIONS and 405)
+	pairs, exists := mvcI.urlActions[url]
+	if !exists {
+		pairs = make(map[method]*ControllerAction, 0)
+		mvcI.urlActions[url] = pairs
+		mvcI.Router.HandleFunc(url, mvcI.wrapFallbackHandler(url)).
+			MatcherFunc(func(request *http.Request, match *mux.RouteMatch) bool {
+				_, exists := pairs[method(request.Method)]
+				return !exists
+			})
+	}
+	pairs[m] = &ControllerAction{c, a}
+
 	mvcI.Router.HandleFunc(url, mvcI.wrapHandler(c, a)).Methods(string(m))
+	mvcI.addRoute(c, a, newRouteDescriptor(url, m))
 }
 
-func (mvcI *MvcInfrastructure) checkViewOnActionBind(c Controller, a Action) {
-	actions, exists := mvcI.views[c]
-	if !exists {
-		logger.Warnf("Added handler for missing view: controller - %v, action - %v", c, a)
-		return
+// wrapFallbackHandler creates the handler of the url requests with http-methods that have no actions bound
+// to the url. The request is passed to the action bound with GET (or the first one) with all handlers
+// of the url, so HEAD is served by the GET handler and OPTIONS and 405 list all methods of the url.
+func (mvcI *MvcInfrastructure) wrapFallbackHandler(url string) func(response http.ResponseWriter, request *http.Request) {
+	return func(response http.ResponseWriter, request *http.Request) {
+		logger.Debugf("url: %s, m: %s", url, request.Method)
+
+		pairs := mvcI.urlActions[url]
+
+		keys := make([]string, 0)
+		methods := make(map[method]*methodDescriptor, 0)
+		for m, pair := range pairs {
+			keys = append(keys, string(m))
+			methods[m] = mvcI.handlers[pair.C][pair.A][m]
+		}
+		sort.Strings(keys)
+
+		pair, exists := pairs[Get]
+		if !exists {
+			pair = pairs[method(keys[0])]
+		}
+
+		mvcI.handleRequest(pair.C, pair.A, methods, response, request)
 	}
+}
 
-	_, exists = actions[a]
-	if !exists {
-		logger.Warnf("Added handler for missing view: controller - %v, action - %v", c, a)
+// allowedMethods returns sorted http-methods answered by the handlers: methods that have
+// handlers, HEAD if there is a GET handler and OPTIONS
+func allowedMethods(methods map[method]*methodDescriptor) []string {
+	result := make([]string, 0)
+	for m := range methods {
+		result = append(result, string(m))
+	}
+
+	if _, exists := methods[Head]; !exists {
+		if _, exists := methods[Get]; exists {
+			result = append(result, string(Head))
+		}
+	}
+	if _, exists := methods[Options]; !exists {
+		result = append(result, string(Options))
+	}
+
+	sort.Strings(result)
 
+	return result
+}
+
+// SetPreflightHandler sets the handler of OPTIONS requests for actions without OPTIONS handlers.
+// Use it to answer CORS preflight requests.
+func (mvcI *MvcInfrastructure) SetPreflightHandler(preflightHandler PreflightHandlerInterface) {
+	mvcI.preflightHandler = preflightHandler
+}
+
+// SetGetFallback enables the legacy behaviour: requests with http-methods that have no
+// handler are served by the GET handler of the action instead of the 405 status code.
+func (mvcI *MvcInfrastructure) SetGetFallback(enabled bool) {
+	mvcI.getFallback = enabled
+}
+
+func (mvcI *MvcInfrastructure) checkViewOnActionBind(c Controller, a Action) {
+	_, err := mvcI.getView(c, a)
+	if err != nil {
+		logger.Warnf("Added handler for missing view: controller - %v, action - %v", c, a)
 		return
 	}
 }
 
 // BindUrl bind a url to the controller/action pair. Works on top of the
-// Gorilla HandleFunc method.
+// Gorilla HandleFunc method. URLFor prefers urls bound by BindUrl over the default ones.
 func (mvcI *MvcInfrastructure) BindUrl(c Controller, a Action, url string) {
 	logger.Trace("")
 	logger.Debugf("c: %v, a: %v, url: %v", c, a, url)
 
 	mvcI.Router.HandleFunc(url, mvcI.wrapHandler(c, a))
+	mvcI.addRoute(c, a, newRouteDescriptor(url, ""))
 }
 
 func (mvcI *MvcInfrastructure) checkHandlerOnUrlBind(c Controller, a Action) {
@@ -77,68 +150,90 @@ func (mvcI *MvcInfrastructure) checkHandlerOnUrlBind(c Controller, a Action) {
 	}
 }
 
-func (mvcI *MvcInfrastructure) callAction(c Controller, a Action, response http.ResponseWriter, request *http.Request) ActionResultInterface {
+// callAction calls the handler of the request http-method from the handlers of the route
+func (mvcI *MvcInfrastructure) callAction(c Controller, a Action, methods map[method]*methodDescriptor, response http.ResponseWriter, request *http.Request, scope *serviceScope) ActionResultInterface {
 	logger.Tracef("c: %v, a: %v", c, a)
 
 	//c = toLowerC(c)
 	//a = toLowerA(a)
 
-	logger.Trace("Search actions")
-	actions, exists := mvcI.handlers[c]
-	if !exists {
-		logger.Errorf("controller not found: %v", c)
-		return NotFoundResult()
-	}
-
-	logger.Trace("Search methods")
-	methods, exists := actions[a]
-	if !exists {
-		logger.Errorf("action not found: %v", a)
+	if methods == nil {
+		logger.Errorf("action not found: %v/%v", c, a)
 		return NotFoundResult()
 	}
 
 	logger.Trace("Search handler")
 	handler, exists := methods[method(request.Method)]
 	if exists {
-		return mvcI.callHandler(handler, c, a, response, request)
+		return mvcI.callHandler(handler, c, a, response, request, scope)
 	}
 
-	logger.Trace("Search handler for get")
-	handler, exists = methods[Get]
-	if exists {
-		return mvcI.callHandler(handler, c, a, response, request)
+	if method(request.Method) == Head {
+		logger.Trace("Search handler for get to answer head")
+		handler, exists = methods[Get]
+		if exists {
+			return mvcI.callHandler(handler, c, a, response, request, scope)
+		}
+	}
+
+	if mvcI.getFallback {
+		logger.Trace("Search handler for get")
+		handler, exists = methods[Get]
+		if exists {
+			return mvcI.callHandler(handler, c, a, response, request, scope)
+		}
 	}
 
-	return NotFoundResult()
+	logger.Errorf("method not allowed: %v", request.Method)
+	return MethodNotAllowedResult(allowedMethods(methods))
 }
 
-func (mvcI *MvcInfrastructure) callHandler(handler *methodDescriptor, c Controller, a Action, response http.ResponseWriter, request *http.Request) ActionResultInterface {
+func (mvcI *MvcInfrastructure) callHandler(handler *methodDescriptor, c Controller, a Action, response http.ResponseWriter, request *http.Request, scope *serviceScope) ActionResultInterface {
 	logger.Trace("")
 
 	invoker := newHandlerInvoker(handler).
+		SetDecoder(mvcI.decoder).
+		SetNormalizers(mvcI.actionNormalizers(handler)).
+		SetServices(scope).
 		AddParam(response).
 		AddParam(request).
 		AddParam(c).
 		AddParam(a).
-		AddValues(request.URL.Query())
+		SetSourceValues(QuerySource, request.URL.Query()).
+		SetSourceValues(HeaderSource, url.Values(request.Header)).
+		SetSourceValues(CookieSource, cookieValues(request))
 
-	if request.Method == "POST" {
-		err := request.ParseForm()
-		if err != nil {
-			logger.Error(err.Error())
-			return ErrorResult(err)
-		}
-		
-		err = request.ParseMultipartForm(defaultMaxMemory)
-		if err != nil {
-			logger.Error(err.Error())
-			return ErrorResult(err)
+	if res := checkStreaming(handler, request); res != nil {
+		return res
+	}
+
+	binder, err := requestBodyBinder(request)
+	if err != nil {
+		logger.Warnf("%v: %s", err, request.Header.Get("Content-Type"))
+		invoker.SetBodyBinderError(err)
+	}
+
+	if binder != nil {
+		invoker.SetBody(newLimitedBody(request.Body, mvcI.maxBodySize), binder)
+	} else {
+		res := mvcI.parseForm(handler, invoker, request)
+		if res != nil {
+			return res
 		}
+	}
+
+	routeValues := mux.Vars(request)
+	if len(routeValues) > 0 {
+		invoker.AddRouteValues(routeValues)
+	}
 
-		invoker.AddValues(request.Form)
+	rets, err := scope.call(mvcI.controllerConstructors[c])
+	if err != nil {
+		logger.Error(err.Error())
+		return ErrorResult(err)
 	}
 
-	contr := mvcI.controllerConstructors[c].Call(emptyValues)[0].Interface().(ControllerInterface)
+	contr := rets[0].Interface().(ControllerInterface)
 	contr.SetController(c)
 	contr.SetAction(a)
 	contr.SetRequest(request)
@@ -148,5 +243,37 @@ func (mvcI *MvcInfrastructure) callHandler(handler *methodDescriptor, c Controll
 	invoker.AddValue("Controller", string(c)).
 		AddValue("Action", string(a))
 
-	return invoker.Invoke()
+	return mvcI.invokeAction(contr, c, a, invoker)
+}
+
+// invokeAction calls the action surrounded by the controller hooks, see ActionExecutingInterface,
+// ActionExecutedInterface, ExceptionHandlerInterface
+func (mvcI *MvcInfrastructure) invokeAction(contr ControllerInterface, c Controller, a Action, invoker *handlerInvoker) (res ActionResultInterface) {
+	if exceptionHandler, ok := contr.(ExceptionHandlerInterface); ok {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Tracef("recovered from panic: %v", err)
+				res = exceptionHandler.OnException(c, a, err)
+				if res == nil {
+					res = ErrorResult(err)
+				}
+			}
+		}()
+	}
+
+	if executing, ok := contr.(ActionExecutingInterface); ok {
+		logger.Trace("OnActionExecuting")
+		res = executing.OnActionExecuting(c, a)
+	}
+
+	if res == nil {
+		res = invoker.Invoke()
+	}
+
+	if executed, ok := contr.(ActionExecutedInterface); ok {
+		logger.Trace("OnActionExecuted")
+		res = executed.OnActionExecuted(c, a, res)
+	}
+
+	return res
 }
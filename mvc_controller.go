@@ -1,51 +1,81 @@
 package trinity
 
 import (
+	"fmt"
 	"reflect"
 )
 
 // BindController registers a specified controller. 
-// Expects parameterless constructor of controller.
+// Constructor parameters are resolved by type from the registered services (see RegisterSingleton,
+// RegisterFactory), so services must be registered before. The constructor is called per request.
+// The controller info is taken from a zero instance of the constructor result type, so GetInfo
+// must not depend on the constructor.
 // Iterates through the controller methods and registers its actions using GetActionInfos.
 func (mvcI *MvcInfrastructure) BindController(constructor interface{}) {
 	logger.Trace("")
 
-	value, controllerInterface := mvcI.reflectAndCheck(constructor)
+	descriptor, controllerInterface := mvcI.reflectAndCheck(constructor)
 	controllerInfo := controllerInterface.GetInfo()
 
 	controller := controllerInfo.GetController()
-	mvcI.controllerConstructors[controller] = value
-	
+	mvcI.controllerConstructors[controller] = descriptor
+
+
 	for _, actionInfo := range controllerInfo.GetActionInfos() {
 		httpMethod := "GET"
 		if actionInfo.method != "" {
 			httpMethod = actionInfo.method
 		}
 
-		mvcI.bindAction(controller, actionInfo.action, method(httpMethod), newMethodDescriptorFromValue(actionInfo.handler))
+		handler := newMethodDescriptorFromValue(actionInfo.handler)
+		handler.maxUploadSize = actionInfo.maxUploadSize
+		handler.normalizers = actionInfo.normalizers
+		if len(actionInfo.params) > 0 {
+			err := handler.setParamNames(actionInfo.params, mvcI.decoder)
+			if err != nil {
+				panic(fmt.Sprintf("Action %v/%v: %v", controller, actionInfo.action, err))
+			}
+		}
+		if err := mvcI.decoder.checkConverterTags(handler.inTypes); err != nil {
+			panic(fmt.Sprintf("Action %v/%v: %v", controller, actionInfo.action, err))
+		}
+
+		mvcI.bindAction(controller, actionInfo.action, method(httpMethod), actionInfo.route, handler)
 	}
 }
 
-func (mvcI *MvcInfrastructure) reflectAndCheck(constructor interface{}) (reflect.Value, ControllerInterface) {
+func (mvcI *MvcInfrastructure) reflectAndCheck(constructor interface{}) (*methodDescriptor, ControllerInterface) {
 	value := reflect.ValueOf(constructor)
 	if value.Kind() != reflect.Func {
 		panic("Incorrect constructor kind. Expected - Func, goted - " + value.Kind().String())
 	}
-	
-	valueType := value.Type()
-	if valueType.NumIn() > 0 {
-		panic("Constructor must be parameterless.")
+
+	descriptor := newMethodDescriptorFromValue(value)
+	err := mvcI.services.check(descriptor.inTypes)
+	if err != nil {
+		panic("Constructor parameters can't be resolved: " + err.Error())
 	}
-	
-	result := value.Call(emptyValues)
-	if len(result) != 1 {
+
+	constructorType := value.Type()
+	if constructorType.NumOut() != 1 {
 		panic("Constructor must return one value")
 	}
-	
-	controllerInterface, ok := result[0].Interface().(ControllerInterface)
+
+	outType := constructorType.Out(0)
+	var instance reflect.Value
+	switch outType.Kind() {
+	case reflect.Interface:
+		panic("Constructor must return a concrete controller type, not " + outType.String())
+	case reflect.Ptr:
+		instance = reflect.New(outType.Elem())
+	default:
+		instance = reflect.New(outType).Elem()
+	}
+
+	controllerInterface, ok := instance.Interface().(ControllerInterface)
 	if !ok {
 		panic("Constructor return value must implement ControllerInterface")
 	}
-	
-	return value, controllerInterface
+
+	return descriptor, controllerInterface
 }
\ No newline at end of file
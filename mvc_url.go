@@ -0,0 +1,100 @@
+package trinity
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// URLFor builds the url of the controller/action pair using the routes that were actually registered for it.
+//
+// Routes bound by BindUrl are preferred over the default action routes, the route for the GET
+// method is preferred over the others. The first route whose segments can be filled from params is used.
+// Params that are not used as route segments are added to the query string, which is escaped and
+// sorted by key.
+//
+// If no route is registered for the controller/action pair (e.g. the handler was added to Router directly)
+// the default "/controller/action" url is returned with params in the query string.
+// Returns an error if params don't contain values for the route segments or the values are invalid.
+func (mvcI *MvcInfrastructure) URLFor(c Controller, a Action, params map[string]string) (string, error) {
+	logger.Tracef("c: %v, a: %v, p: %v", c, a, params)
+
+	routes := mvcI.routes[c][a]
+	if len(routes) == 0 {
+		logger.Debugf("route not found: %v/%v", c, a)
+		return createURL(c, a, params), nil
+	}
+
+	descriptor := findRoute(routes, params)
+	if descriptor == nil {
+		return "", errors.New(fmt.Sprintf("Not enough params for route: controller - %v, action - %v, params - %v", c, a, params))
+	}
+
+	path, err := descriptor.buildPath(params)
+	if err != nil {
+		return "", err
+	}
+
+	query := make(url.Values, 0)
+	for k, v := range params {
+		if !containsString(descriptor.vars, k) {
+			query.Set(k, v)
+		}
+	}
+
+	if len(query) == 0 {
+		return path, nil
+	}
+
+	return path + "?" + query.Encode(), nil
+}
+
+// addRoute stores a route registered for the controller/action pair. See URLFor.
+func (mvcI *MvcInfrastructure) addRoute(c Controller, a Action, descriptor *routeDescriptor) {
+	actions, exists := mvcI.routes[c]
+	if !exists {
+		actions = make(map[Action][]*routeDescriptor, 0)
+		mvcI.routes[c] = actions
+	}
+
+	actions[a] = append(actions[a], descriptor)
+}
+
+// findRoute selects a route for URLFor
+func findRoute(routes []*routeDescriptor, params map[string]string) *routeDescriptor {
+	for _, descriptor := range routes {
+		if descriptor.isCustom() && descriptor.hasVars(params) {
+			return descriptor
+		}
+	}
+
+	for _, descriptor := range routes {
+		if descriptor.method == Get && descriptor.hasVars(params) {
+			return descriptor
+		}
+	}
+
+	for _, descriptor := range routes {
+		if descriptor.hasVars(params) {
+			return descriptor
+		}
+	}
+
+	return nil
+}
+
+// escapePathSegment escapes a value so it can be placed inside a path segment
+func escapePathSegment(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package trinity
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+)
+
+var (
+	controllerType = reflect.TypeOf(Controller(""))
+	actionType     = reflect.TypeOf(Action(""))
+)
+
+// isScalarType returns true for types of parameters that can be bound by name: numbers, strings,
+// bools, types with converters (time.Time and registered by RegisterConverter), slices and pointers of them
+func (decoder *valueDecoder) isScalarType(paramType reflect.Type) bool {
+	if decoder.types[paramType] != nil {
+		return true
+	}
+
+	if paramType == controllerType || paramType == actionType {
+		return false
+	}
+
+	switch paramType.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		return decoder.isScalarType(paramType.Elem())
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// setParamNames assigns the names to the scalar parameters in order of declaration.
+// Returns an error if the number of names doesn't match the number of scalar parameters.
+func (descriptor *methodDescriptor) setParamNames(names []string, decoder *valueDecoder) error {
+	paramNames := make([]string, len(descriptor.inTypes))
+
+	count := 0
+	for i, inType := range descriptor.inTypes {
+		if !decoder.isScalarType(inType) {
+			continue
+		}
+
+		if count < len(names) {
+			paramNames[i] = names[count]
+		}
+		count++
+	}
+
+	if count != len(names) {
+		return errors.New(fmt.Sprintf("%d param names are set for %d scalar parameters", len(names), count))
+	}
+
+	descriptor.paramNames = paramNames
+	return nil
+}
+
+// paramName returns the name of the parameter or an empty string if it's not named
+func (descriptor *methodDescriptor) paramName(index int) string {
+	if descriptor.paramNames == nil {
+		return ""
+	}
+
+	return descriptor.paramNames[index]
+}
+
+// getNamedParamValue creates the value of the named scalar parameter and fills it from values.
+// The value is decoded as a field of a struct, so it's converted the same way as input fields.
+func (invoker *handlerInvoker) getNamedParamValue(name string, paramType reflect.Type) reflect.Value {
+	logger.Debugf("named param: %s", name)
+
+	vals := findValues(invoker.mergedValues(nil), name)
+	if converter := invoker.decoder.typeConverter(paramType); converter != nil {
+		paramValue := reflect.New(paramType).Elem()
+		if len(vals) > 0 {
+			err := setConverted(paramValue, converter, vals)
+			if err != nil {
+				logger.Warnf("convert %s: %v", name, err)
+				invoker.modelState.AddError(name, err.Error())
+			}
+		}
+		return paramValue
+	}
+
+	holderType := reflect.StructOf([]reflect.StructField{{
+		Name: "Value",
+		Type: paramType,
+		Tag:  reflect.StructTag(fmt.Sprintf(`schema:"%s"`, name)),
+	}})
+	holder := reflect.New(holderType)
+
+	values := make(url.Values, 0)
+	if vals != nil {
+		values[name] = vals
+	}
+
+	err := invoker.decoder.decode(holder.Interface(), values)
+	if err != nil {
+		logger.Warnf("decode %s: %v", name, err)
+		addDecodeErrors(err, invoker.modelState)
+	}
+
+	return holder.Elem().Field(0)
+}
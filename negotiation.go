@@ -0,0 +1,188 @@
+package trinity
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormatQueryParam is the query parameter which selects the NegotiatedResult format by
+// the formatter extension ("?format=json"). The extension of the url path (".json") works
+// the same way if the action route allows it.
+const FormatQueryParam = "format"
+
+// Formatter creates the result that writes the vm in the media type of the formatter.
+// Returns nil if the vm can't be written in the media type, the next acceptable formatter is used then.
+type Formatter func(vm interface{}) ActionResultInterface
+
+// formatterDescriptor is a formatter registered by AddFormatter
+type formatterDescriptor struct {
+	mediaType string
+	extension string
+	formatter Formatter
+}
+
+// AddFormatter registers the formatter used by NegotiatedResult for the media type. The extension
+// (without the dot) selects the formatter by FormatQueryParam or the url extension. Formatters
+// registered by default: "text/html" (the controller/action view), "application/json" and
+// "application/xml". The first registered formatter is used when the client accepts any type.
+// The xml formatter needs vms encoding/xml can encode (named structs, not maps, anonymous structs
+// or interface{} trees), other vms are written by the next acceptable formatter.
+func (mvcI *MvcInfrastructure) AddFormatter(mediaType string, extension string, formatter Formatter) {
+	logger.Debugf("formatter: %s, %s", mediaType, extension)
+
+	for _, descriptor := range mvcI.formatters {
+		if descriptor.mediaType == mediaType {
+			descriptor.extension = extension
+			descriptor.formatter = formatter
+			return
+		}
+	}
+
+	mvcI.formatters = append(mvcI.formatters, &formatterDescriptor{mediaType, extension, formatter})
+}
+
+// addDefaultFormatters registers html, json and xml formatters
+func (mvcI *MvcInfrastructure) addDefaultFormatters() {
+	mvcI.AddFormatter("text/html", "html", func(vm interface{}) ActionResultInterface {
+		return ShowView(emptyController, emptyAction, vm)
+	})
+	mvcI.AddFormatter("application/json", "json", JsonResult)
+	mvcI.AddFormatter("application/xml", "xml", formatXml)
+}
+
+// formatXml is the default xml formatter. Returns nil if the vm can't be encoded.
+func formatXml(vm interface{}) ActionResultInterface {
+	data, err := XmlResult(vm).encode()
+	if err != nil {
+		logger.Debugf("xml: %v", err)
+		return nil
+	}
+
+	return ContentResult(string(data), xmlContentType)
+}
+
+// Action result that writes the vm in the format selected by the request: FormatQueryParam, the url
+// extension or the Accept header. Answers 406 if none of the accepted media types has a formatter
+// that can write the vm.
+type NegotiatedActionResult struct {
+	vm interface{}
+}
+
+// Creates a negotiated action result, see AddFormatter
+func NegotiatedResult(vm interface{}) ActionResultInterface {
+	logger.Trace("")
+
+	return &NegotiatedActionResult{vm}
+}
+func (result *NegotiatedActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	response.Header().Add("Vary", "Accept")
+
+	for _, descriptor := range mvcI.negotiateFormatters(request) {
+		res := descriptor.formatter(result.vm)
+		if res != nil {
+			logger.Debugf("formatter: %s", descriptor.mediaType)
+			res.Response(mvcI, c, a, response, request)
+			return
+		}
+	}
+
+	logger.Errorf("not acceptable: %s", request.Header.Get("Accept"))
+	StatusResult(http.StatusNotAcceptable).Response(mvcI, c, a, response, request)
+}
+
+// negotiateFormatters returns the acceptable formatters for the request in order of preference
+func (mvcI *MvcInfrastructure) negotiateFormatters(request *http.Request) []*formatterDescriptor {
+	extension := request.URL.Query().Get(FormatQueryParam)
+	if extension == "" {
+		extension = strings.TrimPrefix(path.Ext(request.URL.Path), ".")
+	}
+
+	if extension != "" {
+		for _, descriptor := range mvcI.formatters {
+			if strings.EqualFold(descriptor.extension, extension) {
+				return []*formatterDescriptor{descriptor}
+			}
+		}
+	}
+
+	accept := request.Header.Get("Accept")
+	if accept == "" {
+		return mvcI.formatters
+	}
+
+	result := make([]*formatterDescriptor, 0)
+	added := make(map[*formatterDescriptor]bool, 0)
+	for _, mediaRange := range parseAccept(accept) {
+		for _, descriptor := range mvcI.formatters {
+			if !added[descriptor] && matchesMediaRange(descriptor.mediaType, mediaRange) {
+				added[descriptor] = true
+				result = append(result, descriptor)
+			}
+		}
+	}
+
+	return result
+}
+
+// matchesMediaRange returns true if the media type matches the media range of the Accept header
+func matchesMediaRange(mediaType string, mediaRange string) bool {
+	switch {
+	case mediaRange == "*/*":
+		return true
+	case strings.HasSuffix(mediaRange, "/*"):
+		return strings.HasPrefix(mediaType, mediaRange[:len(mediaRange)-1])
+	}
+
+	return mediaType == mediaRange
+}
+
+// acceptedRange is a media range of the Accept header with its quality
+type acceptedRange struct {
+	mediaType string
+	quality   float64
+}
+
+type acceptedRanges []*acceptedRange
+
+func (ranges acceptedRanges) Len() int           { return len(ranges) }
+func (ranges acceptedRanges) Swap(i, j int)      { ranges[i], ranges[j] = ranges[j], ranges[i] }
+func (ranges acceptedRanges) Less(i, j int) bool { return ranges[i].quality > ranges[j].quality }
+
+// parseAccept returns the media ranges of the Accept header ordered by quality.
+// Ranges with zero quality and invalid ones are skipped.
+func parseAccept(accept string) []string {
+	ranges := make(acceptedRanges, 0)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, exists := params["q"]; exists {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		if quality > 0 {
+			ranges = append(ranges, &acceptedRange{mediaType, quality})
+		}
+	}
+
+	sort.Stable(ranges)
+
+	result := make([]string, len(ranges))
+	for i, accepted := range ranges {
+		result[i] = accepted.mediaType
+	}
+
+	return result
+}
@@ -0,0 +1,63 @@
+package trinity
+
+import (
+	"code.google.com/p/go.text/unicode/norm"
+	"strings"
+)
+
+// Normalizer transforms a request value before it's bound to an action argument.
+// Normalizers are off by default, they are added by AddNormalizer or set per action
+// by ActionInfo.Normalizers. Values are normalized on copies, request.Form is left as is.
+type Normalizer func(value string) string
+
+var (
+	// TrimSpace removes leading and trailing white space
+	TrimSpace Normalizer = strings.TrimSpace
+
+	// StripQuotes removes double quotes surrounding the value, added when javascript
+	// strings are passed as parameters
+	StripQuotes Normalizer = stripQuotes
+
+	// NormalizeUnicode converts the value to the Unicode normalization form C
+	NormalizeUnicode Normalizer = norm.NFC.String
+)
+
+func stripQuotes(value string) string {
+	if len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+// AddNormalizer adds the normalizer applied to values of all actions, except the ones with their
+// own normalizers set by ActionInfo.Normalizers. Normalizers are applied in order of addition.
+func (mvcI *MvcInfrastructure) AddNormalizer(normalizer Normalizer) {
+	mvcI.normalizers = append(mvcI.normalizers, normalizer)
+}
+
+// actionNormalizers returns the normalizers of the handler
+func (mvcI *MvcInfrastructure) actionNormalizers(handler *methodDescriptor) []Normalizer {
+	if handler.normalizers != nil {
+		return handler.normalizers
+	}
+
+	return mvcI.normalizers
+}
+
+// normalize returns the normalized copy of the values
+func (invoker *handlerInvoker) normalize(vals []string) []string {
+	if len(invoker.normalizers) == 0 || vals == nil {
+		return vals
+	}
+
+	result := make([]string, len(vals))
+	for i, val := range vals {
+		for _, normalizer := range invoker.normalizers {
+			val = normalizer(val)
+		}
+		result[i] = val
+	}
+
+	return result
+}
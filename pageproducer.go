@@ -5,45 +5,57 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"path/filepath"
 )
 
-// renderPage renders a page with any dependencies (like master pages or template pages
-// for inner elements).
-func renderPage(vm interface{}, templateDescr *templateDescriptor) (html []byte, err error) {
+// compilePage parses a page with any dependencies (like master pages or template pages
+// for inner elements) into a single template. The result is safe for concurrent execution
+// and is cached in the templateDescriptor, so files are parsed only once.
+// Funcs are available to the page, master page and additional templates.
+func compilePage(templateDescr *templateDescriptor, funcs template.FuncMap) (pageTemplate *template.Template, err error) {
 	logger.Trace("")
 	logger.Debugf("index: %s, master: %s", templateDescr.templatePath, templateDescr.masterPage)
 
-	var pageTemplate *template.Template = nil
+	files := make([]string, 0)
 	if templateDescr.masterPage != "" {
-		logger.Trace("parse master page")
-		pageTemplate, err = template.ParseFiles(templateDescr.masterPage)
-		if err != nil {
-			return nil, err
-		}
-		
-		logger.Trace("funcs")
-		pageTemplate.Funcs(template.FuncMap{"equals": equals})
-
 		logger.Trace("parse page to master")
-		_, err = pageTemplate.ParseFiles(templateDescr.templatePath)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		logger.Trace("parse page without master")
-		pageTemplate, err = template.ParseFiles(templateDescr.templatePath)
-		if err != nil {
-			return nil, err
-		}
+		files = append(files, templateDescr.masterPage)
 	}
-	
+	files = append(files, templateDescr.templatePath)
+
 	for _, pagePath := range templateDescr.additionalTemplates {
 		logger.Debugf("dep: %s", pagePath)
+		files = append(files, pagePath)
+	}
 
-		_, err = pageTemplate.ParseFiles(pagePath)
+	// The first file is executed, as with template.ParseFiles
+	pageTemplate, err = template.New(filepath.Base(files[0])).Funcs(funcs).ParseFiles(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pageTemplate, nil
+}
+
+// renderPage executes the compiled page template using vm as the page data.
+// If funcs are specified, the cached template is cloned and funcs replace the ones
+// with the same names for this render only. Replaced funcs must be present at compile time.
+func renderPage(vm interface{}, templateDescr *templateDescriptor, funcs template.FuncMap) (html []byte, err error) {
+	logger.Trace("")
+	logger.Debugf("index: %s", templateDescr.templatePath)
+
+	pageTemplate := templateDescr.template
+	if pageTemplate == nil {
+		return nil, errors.New(fmt.Sprintf("Template is not compiled: %s", templateDescr.templatePath))
+	}
+
+	if len(funcs) > 0 {
+		logger.Trace("clone")
+		pageTemplate, err = templateDescr.prototype.Clone()
 		if err != nil {
 			return nil, err
 		}
+		pageTemplate.Funcs(funcs)
 	}
 
 	logger.Trace("template execute")
@@ -59,12 +71,3 @@ func renderPage(vm interface{}, templateDescr *templateDescriptor) (html []byte,
 
 	return htmlBuffer.Bytes(), nil
 }
-
-
-func equals(args ...interface{}) bool {
-	if len(args) != 2 {
-		return false
-	}
-	
-	return args[0] == args[1]
-}
\ No newline at end of file
@@ -0,0 +1,16 @@
+package trinity
+
+import (
+	"net/http"
+)
+
+// PreflightHandlerInterface handles OPTIONS requests for actions that have no OPTIONS handler,
+// e.g. adds CORS headers to preflight requests. allowedMethods are http-methods the action answers.
+// It's called after filters but before the access checker, preflight requests carry no credentials.
+//
+// Returns nil to send the default response: the Allow header with the 200 status code.
+//
+// Returns ActionResult to use it as the response instead.
+type PreflightHandlerInterface interface {
+	HandlePreflight(c Controller, a Action, allowedMethods []string, response http.ResponseWriter, request *http.Request) ActionResultInterface
+}
@@ -0,0 +1,116 @@
+package trinity
+
+import (
+	"net/http"
+)
+
+// Action result that adds the status code, headers and cookies to the response of another result.
+// They are applied when the result starts writing the response, so they replace the result's own
+// headers with the same names. The status replaces only the default 200 status of the result,
+// so partial content, not modified and error responses keep their own. Created by WithStatus,
+// WithHeader and WithCookie:
+//
+//	return mvc.WithCookie(mvc.WithStatus(mvc.ShowView("", "", vm), 422), &http.Cookie{Name: "step", Value: "2"})
+type DecoratedActionResult struct {
+	result  ActionResultInterface
+	status  int // 0 to keep the result status
+	headers http.Header
+	cookies []*http.Cookie
+}
+
+// WithStatus sets the status code of the result response if the result answers 200
+func WithStatus(result ActionResultInterface, status int) ActionResultInterface {
+	logger.Trace("")
+	logger.Debugf("status: %v", status)
+
+	decorated := decorate(result)
+	decorated.status = status
+	return decorated
+}
+
+// WithHeader adds the header to the result response
+func WithHeader(result ActionResultInterface, name string, value string) ActionResultInterface {
+	logger.Trace("")
+	logger.Debugf("header: %s: %s", name, value)
+
+	decorated := decorate(result)
+	decorated.headers.Add(name, value)
+	return decorated
+}
+
+// WithCookie adds the cookie to the result response
+func WithCookie(result ActionResultInterface, cookie *http.Cookie) ActionResultInterface {
+	logger.Trace("")
+	logger.Debugf("cookie: %s", cookie.Name)
+
+	decorated := decorate(result)
+	decorated.cookies = append(decorated.cookies, cookie)
+	return decorated
+}
+
+// decorate returns a new decorated result. Decorations of the decorated result are copied,
+// so the decorators are not nested.
+func decorate(result ActionResultInterface) *DecoratedActionResult {
+	decorated := new(DecoratedActionResult)
+
+	decorated.result = result
+	decorated.headers = make(http.Header, 0)
+	decorated.cookies = make([]*http.Cookie, 0)
+
+	if source, ok := result.(*DecoratedActionResult); ok {
+		decorated.result = source.result
+		decorated.status = source.status
+		for name, values := range source.headers {
+			decorated.headers[name] = append([]string(nil), values...)
+		}
+		decorated.cookies = append(decorated.cookies, source.cookies...)
+	}
+
+	return decorated
+}
+
+func (decorated *DecoratedActionResult) Response(mvcI *MvcInfrastructure, c Controller, a Action, response http.ResponseWriter, request *http.Request) {
+	logger.Trace("")
+
+	writer := &decoratedResponseWriter{ResponseWriter: response, decorated: decorated}
+	if decorated.result != nil {
+		decorated.result.Response(mvcI, c, a, writer, request)
+	}
+
+	writer.WriteHeader(http.StatusOK)
+}
+
+// decoratedResponseWriter applies the decorations before the header is written
+type decoratedResponseWriter struct {
+	http.ResponseWriter
+
+	decorated   *DecoratedActionResult
+	wroteHeader bool
+}
+
+func (writer *decoratedResponseWriter) WriteHeader(status int) {
+	if writer.wroteHeader {
+		return
+	}
+	writer.wroteHeader = true
+
+	for name, values := range writer.decorated.headers {
+		writer.Header()[name] = values
+	}
+
+	for _, cookie := range writer.decorated.cookies {
+		http.SetCookie(writer, cookie)
+	}
+
+	if writer.decorated.status != 0 && status == http.StatusOK {
+		status = writer.decorated.status
+	}
+
+	writer.ResponseWriter.WriteHeader(status)
+}
+
+func (writer *decoratedResponseWriter) Write(data []byte) (int, error) {
+	writer.WriteHeader(http.StatusOK)
+
+	return writer.ResponseWriter.Write(data)
+}
@@ -0,0 +1,113 @@
+package trinity
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	defaultRouteVarPattern = "[^/]+"
+)
+
+// routeDescriptor stores a route registered for a controller/action pair. Used to build urls.
+type routeDescriptor struct {
+	template string   // url template, e.g. "/product/show/{id:[0-9]+}"
+	method   method   // http-method of the action, empty for routes bound by BindUrl
+	vars     []string // names of the template segments
+	parts    []string // template split by segments: literal, segment, literal, ...
+
+	patterns map[string]*regexp.Regexp // segment constraints
+}
+
+// newRouteDescriptor creates a new routeDescriptor object
+func newRouteDescriptor(template string, m method) *routeDescriptor {
+	descriptor := new(routeDescriptor)
+
+	descriptor.template = template
+	descriptor.method = m
+	descriptor.vars = make([]string, 0)
+	descriptor.parts = make([]string, 0)
+	descriptor.patterns = make(map[string]*regexp.Regexp, 0)
+
+	descriptor.parseTemplate()
+
+	return descriptor
+}
+
+// parseTemplate extracts segments from the route template.
+// Segments look like "{name}" or "{name:pattern}", pattern can contain braces.
+func (descriptor *routeDescriptor) parseTemplate() {
+	template := descriptor.template
+
+	level := 0
+	start := 0
+	literalStart := 0
+	for i, char := range template {
+		switch char {
+		case '{':
+			if level == 0 {
+				start = i + 1
+			}
+			level++
+		case '}':
+			level--
+			if level != 0 {
+				continue
+			}
+
+			name := template[start:i]
+			pattern := defaultRouteVarPattern
+			if colon := strings.Index(name, ":"); colon >= 0 {
+				pattern = name[colon+1:]
+				name = name[:colon]
+			}
+			name = strings.TrimSpace(name)
+
+			descriptor.vars = append(descriptor.vars, name)
+			descriptor.parts = append(descriptor.parts, template[literalStart:start-1], name)
+			descriptor.patterns[name] = regexp.MustCompile("^(?:" + pattern + ")$")
+
+			literalStart = i + 1
+		}
+	}
+
+	descriptor.parts = append(descriptor.parts, template[literalStart:])
+}
+
+// isCustom returns true if route was bound by BindUrl
+func (descriptor *routeDescriptor) isCustom() bool {
+	return descriptor.method == ""
+}
+
+// hasVars returns true if params contain values for all template segments
+func (descriptor *routeDescriptor) hasVars(params map[string]string) bool {
+	for _, name := range descriptor.vars {
+		if _, exists := params[name]; !exists {
+			return false
+		}
+	}
+
+	return true
+}
+
+// buildPath fills template segments with escaped param values. Values must match segment constraints.
+func (descriptor *routeDescriptor) buildPath(params map[string]string) (string, error) {
+	path := ""
+	for i, part := range descriptor.parts {
+		if i%2 == 0 {
+			path += part
+			continue
+		}
+
+		value := params[part]
+		if !descriptor.patterns[part].MatchString(value) {
+			return "", errors.New(fmt.Sprintf("Value %q doesn't match route segment %q of %s", value, part, descriptor.template))
+		}
+
+		path += escapePathSegment(value)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,280 @@
+package trinity
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+var (
+	errorType          = reflect.TypeOf((*error)(nil)).Elem()
+	responseWriterType = reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()
+	requestType        = reflect.TypeOf((*http.Request)(nil))
+)
+
+// serviceContainer stores services that are injected into controller constructors and action
+// parameters by type.
+//
+// Singletons are created by the app and shared between requests. Factories are funcs that
+// create a service once per request, their parameters are resolved the same way as constructor
+// parameters. Request objects (http.ResponseWriter, *http.Request) can be used as factory and
+// constructor parameters too.
+type serviceContainer struct {
+	singletons map[reflect.Type]reflect.Value
+	factories  map[reflect.Type]*methodDescriptor
+	cleanups   map[reflect.Type][]reflect.Value // funcs called for services created by factories after the request
+}
+
+func newServiceContainer() *serviceContainer {
+	container := new(serviceContainer)
+
+	container.singletons = make(map[reflect.Type]reflect.Value, 0)
+	container.factories = make(map[reflect.Type]*methodDescriptor, 0)
+	container.cleanups = make(map[reflect.Type][]reflect.Value, 0)
+
+	return container
+}
+
+// RegisterSingleton registers a service shared between all requests. The service is injected
+// into constructor parameters of its type. Register services before BindController.
+func (mvcI *MvcInfrastructure) RegisterSingleton(service interface{}) {
+	value := reflect.ValueOf(service)
+	mvcI.services.addSingleton(value.Type(), value)
+}
+
+// RegisterSingletonAs registers a service shared between all requests as an implementation of
+// the interface. The interface is passed as a nil pointer to it:
+//
+//	mvcI.RegisterSingletonAs((*Storage)(nil), NewSqlStorage(db))
+func (mvcI *MvcInfrastructure) RegisterSingletonAs(iface interface{}, service interface{}) {
+	ifaceType := interfaceType(iface)
+
+	value := reflect.ValueOf(service)
+	if !value.Type().Implements(ifaceType) {
+		panic(fmt.Sprintf("%v doesn't implement %v", value.Type(), ifaceType))
+	}
+
+	mvcI.services.addSingleton(ifaceType, value)
+}
+
+// RegisterFactory registers a func creating a service per request. The func must return the service
+// or the service and an error. The service is created once per request, when it's needed.
+func (mvcI *MvcInfrastructure) RegisterFactory(factory interface{}) {
+	descriptor := newFactoryDescriptor(factory)
+	mvcI.services.addFactory(descriptor.value.Type().Out(0), descriptor)
+}
+
+// RegisterFactoryAs registers a func creating a per request service as an implementation of the
+// interface. See RegisterSingletonAs and RegisterFactory.
+func (mvcI *MvcInfrastructure) RegisterFactoryAs(iface interface{}, factory interface{}) {
+	ifaceType := interfaceType(iface)
+
+	descriptor := newFactoryDescriptor(factory)
+	if !descriptor.value.Type().Out(0).Implements(ifaceType) {
+		panic(fmt.Sprintf("%v doesn't implement %v", descriptor.value.Type().Out(0), ifaceType))
+	}
+
+	mvcI.services.addFactory(ifaceType, descriptor)
+}
+
+// RegisterCleanup registers a func called for every service of its parameter type created by a factory.
+// Cleanups are called after the request result has been written, in reverse order of the services creation:
+//
+//	mvcI.RegisterFactory(func(db *sql.DB) (*sql.Tx, error) { return db.Begin() })
+//	mvcI.RegisterCleanup(func(tx *sql.Tx) { tx.Rollback() }) // no-op if the action has committed
+func (mvcI *MvcInfrastructure) RegisterCleanup(cleanup interface{}) {
+	value := reflect.ValueOf(cleanup)
+	if value.Kind() != reflect.Func || value.Type().NumIn() != 1 {
+		panic("Cleanup must be a func with one parameter")
+	}
+
+	serviceType := value.Type().In(0)
+	logger.Debugf("cleanup: %v", serviceType)
+
+	mvcI.services.cleanups[serviceType] = append(mvcI.services.cleanups[serviceType], value)
+}
+
+func (container *serviceContainer) addSingleton(serviceType reflect.Type, value reflect.Value) {
+	logger.Debugf("singleton: %v", serviceType)
+
+	delete(container.factories, serviceType)
+	container.singletons[serviceType] = value
+}
+
+func (container *serviceContainer) addFactory(serviceType reflect.Type, descriptor *methodDescriptor) {
+	logger.Debugf("factory: %v", serviceType)
+
+	delete(container.singletons, serviceType)
+	container.factories[serviceType] = descriptor
+}
+
+// check returns an error if some of the types can't be resolved
+func (container *serviceContainer) check(types []reflect.Type) error {
+	return container.checkTypes(types, make(map[reflect.Type]bool, 0))
+}
+
+func (container *serviceContainer) checkTypes(types []reflect.Type, resolving map[reflect.Type]bool) error {
+	for _, serviceType := range types {
+		if serviceType == responseWriterType || serviceType == requestType {
+			continue
+		}
+
+		if _, exists := container.singletons[serviceType]; exists {
+			continue
+		}
+
+		factory, exists := container.factories[serviceType]
+		if !exists {
+			return errors.New(fmt.Sprintf("Service is not registered: %v", serviceType))
+		}
+
+		if resolving[serviceType] {
+			return errors.New(fmt.Sprintf("Circular service dependency: %v", serviceType))
+		}
+
+		resolving[serviceType] = true
+		err := container.checkTypes(factory.inTypes, resolving)
+		if err != nil {
+			return err
+		}
+		delete(resolving, serviceType)
+	}
+
+	return nil
+}
+
+// serviceScope resolves services for a single request. Services created by factories are
+// cached, so each of them is created once per request.
+type serviceScope struct {
+	container *serviceContainer
+	response  http.ResponseWriter
+	request   *http.Request
+	instances map[reflect.Type]reflect.Value
+	created   []reflect.Type // types of services created by factories, in order of creation
+}
+
+func newServiceScope(container *serviceContainer, response http.ResponseWriter, request *http.Request) *serviceScope {
+	scope := new(serviceScope)
+
+	scope.container = container
+	scope.response = response
+	scope.request = request
+	scope.instances = make(map[reflect.Type]reflect.Value, 0)
+	scope.created = make([]reflect.Type, 0)
+
+	return scope
+}
+
+// resolve returns the service of the type
+func (scope *serviceScope) resolve(serviceType reflect.Type) (reflect.Value, error) {
+	logger.Tracef("resolve: %v", serviceType)
+
+	switch serviceType {
+	case responseWriterType:
+		return reflect.ValueOf(&scope.response).Elem(), nil
+	case requestType:
+		return reflect.ValueOf(scope.request), nil
+	}
+
+	if singleton, exists := scope.container.singletons[serviceType]; exists {
+		return singleton, nil
+	}
+
+	if instance, exists := scope.instances[serviceType]; exists {
+		return instance, nil
+	}
+
+	factory, exists := scope.container.factories[serviceType]
+	if !exists {
+		return reflect.Value{}, errors.New(fmt.Sprintf("Service is not registered: %v", serviceType))
+	}
+
+	rets, err := scope.call(factory)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	if len(rets) > 1 && !rets[1].IsNil() {
+		return reflect.Value{}, rets[1].Interface().(error)
+	}
+
+	instance := rets[0]
+	if instance.Type() != serviceType {
+		instance = instance.Convert(serviceType)
+	}
+
+	scope.instances[serviceType] = instance
+	scope.created = append(scope.created, serviceType)
+	return instance, nil
+}
+
+// canResolve returns true if the service of the type is registered
+func (scope *serviceScope) canResolve(serviceType reflect.Type) bool {
+	if _, exists := scope.container.singletons[serviceType]; exists {
+		return true
+	}
+
+	_, exists := scope.container.factories[serviceType]
+	return exists
+}
+
+// cleanup calls registered cleanups for services created by factories
+func (scope *serviceScope) cleanup() {
+	for i := len(scope.created) - 1; i >= 0; i-- {
+		serviceType := scope.created[i]
+		for _, cleanup := range scope.container.cleanups[serviceType] {
+			scope.callCleanup(cleanup, scope.instances[serviceType])
+		}
+	}
+}
+
+func (scope *serviceScope) callCleanup(cleanup reflect.Value, instance reflect.Value) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Errorf("cleanup of %v: %v", instance.Type(), err)
+		}
+	}()
+
+	cleanup.Call([]reflect.Value{instance})
+}
+
+// call calls the func resolving its parameters
+func (scope *serviceScope) call(descriptor *methodDescriptor) ([]reflect.Value, error) {
+	args := make([]reflect.Value, len(descriptor.inTypes))
+	for i, inType := range descriptor.inTypes {
+		arg, err := scope.resolve(inType)
+		if err != nil {
+			return nil, err
+		}
+
+		args[i] = arg
+	}
+
+	return descriptor.value.Call(args), nil
+}
+
+// newFactoryDescriptor checks the factory signature and creates a method descriptor for it
+func newFactoryDescriptor(factory interface{}) *methodDescriptor {
+	descriptor := newMethodDescriptorFromMethod(factory)
+
+	factoryType := descriptor.value.Type()
+	switch {
+	case factoryType.NumOut() == 1:
+	case factoryType.NumOut() == 2 && factoryType.Out(1) == errorType:
+	default:
+		panic("Factory must return a service or a service and an error")
+	}
+
+	return descriptor
+}
+
+// interfaceType returns the interface type from a nil pointer to it
+func interfaceType(iface interface{}) reflect.Type {
+	ptrType := reflect.TypeOf(iface)
+	if ptrType == nil || ptrType.Kind() != reflect.Ptr || ptrType.Elem().Kind() != reflect.Interface {
+		panic("Interface must be passed as a nil pointer to it, e.g. (*MyInterface)(nil)")
+	}
+
+	return ptrType.Elem()
+}
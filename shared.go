@@ -2,20 +2,22 @@ package trinity
 
 import (
 	"net/http"
+	"net/url"
 	"reflect"
 	"strings"
 )
 
 var (
-	Post   = method("POST")
-	Get    = method("GET")
-	Put    = method("PUT")
-	Delete = method("DELETE")
-	Head   = method("HEAD")
+	Post    = method("POST")
+	Get     = method("GET")
+	Put     = method("PUT")
+	Delete  = method("DELETE")
+	Patch   = method("PATCH")
+	Head    = method("HEAD")
+	Options = method("OPTIONS")
 
 	emptyController = Controller("")
 	emptyAction     = Action("")
-	emptyValues = make([]reflect.Value, 0)
 )
 
 type httpHandler func(response http.ResponseWriter, request *http.Request) ActionResultInterface
@@ -63,24 +65,35 @@ func toLowerC(c Controller) Controller {
 	return Controller(strings.ToLower(string(c)))
 }
 
+// createURL returns the default "/controller/action" url. Params are escaped and sorted by key.
+// Use MvcInfrastructure.URLFor to get the url that is actually registered.
 func createURL(c Controller, a Action, params map[string]string) string {
-	url := "/" + string(c) + "/" + string(a)
-
-	query := ""
-	if params != nil {
-		for k, v := range params {
-			if len(query) != 0 {
-				query += "&"
-			}
-			query += k + "=" + v
-		}
+	path := "/" + string(c) + "/" + string(a)
+
+	query := make(url.Values, 0)
+	for k, v := range params {
+		query.Set(k, v)
 	}
 
 	if len(query) != 0 {
-		url += "?" + query
+		path += "?" + query.Encode()
+	}
+
+	return path
+}
+
+// createRouteURL returns the url template for the controller/action pair. See ActionInfo.Route.
+func createRouteURL(c Controller, a Action, route string) string {
+	if strings.HasPrefix(route, "/") {
+		return route
+	}
+
+	path := createURL(c, a, nil)
+	if len(route) == 0 {
+		return path
 	}
 
-	return url
+	return path + "/" + route
 }
 
 func parseURL(url string) (c Controller, a Action) {
@@ -0,0 +1,198 @@
+package trinity
+
+import (
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Sources of the request values. Source names are also used as struct tags selecting
+// the source of an action input field:
+//
+//	type ShowInput struct {
+//		ID      int       `route:"id"`
+//		Page    int       `query:"page"`
+//		Comment string    `form:"comment"`
+//		Token   string    `header:"X-Token"`
+//		Session string    `cookie:"sid"`
+//		Data    *ShowData `body:"true"`
+//	}
+//
+// A tagged field is filled only from its source, by the name from the tag. The body field gets the
+// json/xml request body instead of the whole input.
+//
+// Untagged fields are filled by name from route, form and query values, in that precedence.
+// Values are converted by the same decoder in both cases, so conversion errors go to the ModelState.
+const (
+	RouteSource  = "route"
+	QuerySource  = "query"
+	FormSource   = "form"
+	HeaderSource = "header"
+	CookieSource = "cookie"
+	BodySource   = "body"
+)
+
+var (
+	// sources filling the untagged fields, in increasing precedence
+	untaggedSources = []string{QuerySource, FormSource, RouteSource}
+
+	// sources that can be set by tags, except the body
+	taggedSources = []string{RouteSource, QuerySource, FormSource, HeaderSource, CookieSource}
+)
+
+// taggedField is a field of the action input with the source tag
+type taggedField struct {
+	field  reflect.StructField
+	source string
+	name   string // value name in the source
+}
+
+// getTaggedFields returns the fields of the struct with source tags, except the ones filled by converters
+func (decoder *valueDecoder) getTaggedFields(structType reflect.Type) []*taggedField {
+	result := make([]*taggedField, 0)
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tagged := newTaggedField(field)
+		if tagged != nil && field.Tag.Get(ConverterTag) == "" && decoder.typeConverter(field.Type) == nil {
+			result = append(result, tagged)
+		}
+	}
+
+	return result
+}
+
+// newTaggedField returns the tagged field or nil if the field has no source tag
+func newTaggedField(field reflect.StructField) *taggedField {
+	for _, source := range taggedSources {
+		name := field.Tag.Get(source)
+		if name != "" {
+			return &taggedField{field, source, name}
+		}
+	}
+
+	return nil
+}
+
+// getBodyField returns the index of the field with the body tag or -1
+func getBodyField(structType reflect.Type) int {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath == "" && field.Tag.Get(BodySource) != "" {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// cookieValues returns request cookies as values
+func cookieValues(request *http.Request) url.Values {
+	values := make(url.Values, 0)
+	for _, cookie := range request.Cookies() {
+		values.Add(cookie.Name, cookie.Value)
+	}
+
+	return values
+}
+
+// sourceValues returns values of the field from its source
+func (invoker *handlerInvoker) sourceValues(field *taggedField) []string {
+	values := invoker.sources[field.source]
+	if values == nil {
+		return nil
+	}
+
+	switch field.source {
+	case HeaderSource:
+		return invoker.normalize(values[textproto.CanonicalMIMEHeaderKey(field.name)])
+	case CookieSource:
+		return invoker.normalize(values[field.name])
+	}
+
+	return invoker.normalize(findValues(values, field.name))
+}
+
+// findValues returns the values with the name, case insensitive if there are no exact match
+func findValues(values url.Values, name string) []string {
+	if vals, exists := values[name]; exists {
+		return vals
+	}
+
+	for existing, vals := range values {
+		if strings.EqualFold(existing, name) {
+			return vals
+		}
+	}
+
+	return nil
+}
+
+// mergedValues returns normalized values used to fill the untagged fields: values added by AddValue
+// and values of the untagged sources. Values of the sources with higher precedence replace the others.
+// Excluded names (of the tagged fields) are removed, so the fields are not filled twice.
+func (invoker *handlerInvoker) mergedValues(excluded []string) url.Values {
+	result := make(url.Values, 0)
+	setValues(result, invoker.values)
+
+	for _, source := range untaggedSources {
+		setValues(result, invoker.sources[source])
+	}
+
+	for _, name := range excluded {
+		deleteValues(result, name)
+	}
+
+	for name, vals := range result {
+		result[name] = invoker.normalize(vals)
+	}
+
+	return result
+}
+
+// bindTaggedFields fills tagged fields of the struct from their sources
+func (invoker *handlerInvoker) bindTaggedFields(input reflect.Value, taggedFields []*taggedField) {
+	if len(taggedFields) == 0 {
+		return
+	}
+
+	values := make(url.Values, 0)
+	for _, field := range taggedFields {
+		vals := invoker.sourceValues(field)
+		if len(vals) > 0 {
+			values[fieldName(field.field)] = vals
+		}
+	}
+
+	err := invoker.decoder.decode(input.Interface(), values)
+	if err != nil {
+		logger.Warnf("decode tagged: %v", err)
+		addDecodeErrors(err, invoker.modelState)
+	}
+}
+
+// setValues copies values into the target replacing the values with the same name (case insensitive)
+func setValues(target url.Values, values url.Values) {
+	for name, vals := range values {
+		deleteValues(target, name)
+
+		copied := make([]string, len(vals))
+		copy(copied, vals)
+		target[name] = copied
+	}
+}
+
+// deleteValues deletes the values with the name (case insensitive)
+func deleteValues(target url.Values, name string) {
+	for existing := range target {
+		if strings.EqualFold(existing, name) {
+			delete(target, existing)
+		}
+	}
+}
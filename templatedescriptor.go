@@ -1,6 +1,7 @@
 package trinity
 
 import (
+	"html/template"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -21,16 +22,19 @@ var (
 	AdditionalTemplateOption = "AdditionalTemplate"
 )
 
-// templateDescriptor stores the options section for a template
+// templateDescriptor stores the options section for a template and the compiled template itself
 type templateDescriptor struct {
 	templatePath string
 
 	additionalTemplates []string
 	masterPage          string
+
+	template  *template.Template // compiled page, shared between requests
+	prototype *template.Template // never executed copy of the page, used to create per-render clones
 }
 
 // newTemplateDescriptor creates a new templateDescriptor object
-func newTemplateDescriptor(viewsFolder string, templatePath string) (*templateDescriptor, error) {
+func newTemplateDescriptor(viewsFolder string, templatePath string, funcs template.FuncMap) (*templateDescriptor, error) {
 	logger.Trace("")
 
 	template := new(templateDescriptor)
@@ -43,9 +47,41 @@ func newTemplateDescriptor(viewsFolder string, templatePath string) (*templateDe
 		return nil, err
 	}
 
+	template.template, err = compilePage(template, funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	// html/template can't clone executed templates
+	template.prototype, err = template.template.Clone()
+	if err != nil {
+		return nil, err
+	}
+
 	return template, nil
 }
 
+// dependsOn returns true if the file is the template itself, its master page or
+// one of its additional templates
+func (template *templateDescriptor) dependsOn(path string) bool {
+	path = filepath.Clean(path)
+
+	if path == filepath.Clean(template.templatePath) {
+		return true
+	}
+	if template.masterPage != "" && path == filepath.Clean(template.masterPage) {
+		return true
+	}
+
+	for _, additionalTemplate := range template.additionalTemplates {
+		if path == filepath.Clean(additionalTemplate) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (template *templateDescriptor) parseOptions(viewsFolder string) error {
 	file, err := os.Open(template.templatePath)
 	if err != nil {
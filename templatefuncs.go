@@ -0,0 +1,147 @@
+package trinity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AddTemplateFunc registers a func that can be used in every view. Funcs are added to views when
+// they are compiled, so register them before ParseViewsFolder (or call ReloadViews afterwards).
+// Funcs with the name of a built-in func replace it.
+//
+// Built-in funcs:
+//
+//	equals a b                  - a == b
+//	url "controller" "action" "key" value ...
+//	                            - url of the controller/action pair built by URLFor, pairs of
+//	                              key/value arguments are used as params
+//	static "css/site.css"       - path of the static file served by ServeStatic
+//	date "2006-01-02" t         - formats time.Time using the layout
+//	number 2 n                  - formats a number with the specified count of decimals
+//	json v                      - encodes v as json, result can be used inside scripts
+//	safeHTML s, safeAttr s, safeJS s, safeURL s
+//	                            - mark s as trusted content that doesn't need escaping
+//	hasErrors, fieldErrors "Name"
+//	                            - model state errors, see ShowViewWithModelState
+func (mvcI *MvcInfrastructure) AddTemplateFunc(name string, fn interface{}) {
+	logger.Trace("")
+	logger.Debugf("name: %s", name)
+
+	mvcI.templateFuncs[name] = fn
+}
+
+// AddTemplateFuncs calls AddTemplateFunc for every func in the map.
+func (mvcI *MvcInfrastructure) AddTemplateFuncs(funcs template.FuncMap) {
+	for name, fn := range funcs {
+		mvcI.AddTemplateFunc(name, fn)
+	}
+}
+
+// getTemplateFuncs returns built-in funcs together with the registered ones
+func (mvcI *MvcInfrastructure) getTemplateFuncs() template.FuncMap {
+	funcs := template.FuncMap{
+		"equals":   equals,
+		"url":      mvcI.urlFunc,
+		"static":   mvcI.staticFunc,
+		"date":     formatDate,
+		"number":   formatNumber,
+		"json":     toJson,
+		"safeHTML": safeHTML,
+		"safeAttr": safeAttr,
+		"safeJS":   safeJS,
+		"safeURL":  safeURL,
+
+		// replaced when the view is shown with a model state
+		"hasErrors":   noErrors,
+		"fieldErrors": noFieldErrors,
+	}
+
+	for name, fn := range mvcI.templateFuncs {
+		funcs[name] = fn
+	}
+
+	return funcs
+}
+
+func (mvcI *MvcInfrastructure) urlFunc(c string, a string, pairs ...interface{}) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", errors.New("url: params must be key/value pairs")
+	}
+
+	params := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		params[fmt.Sprint(pairs[i])] = fmt.Sprint(pairs[i+1])
+	}
+
+	return mvcI.URLFor(Controller(c), Action(a), params)
+}
+
+func (mvcI *MvcInfrastructure) staticFunc(path string) string {
+	return strings.TrimRight(mvcI.staticPrefix, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+func equals(args ...interface{}) bool {
+	if len(args) != 2 {
+		return false
+	}
+
+	return args[0] == args[1]
+}
+
+func formatDate(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+func formatNumber(decimals int, number interface{}) (string, error) {
+	value := reflect.ValueOf(number)
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatFloat(float64(value.Int()), 'f', decimals, 64), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatFloat(float64(value.Uint()), 'f', decimals, 64), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', decimals, 64), nil
+	}
+
+	return "", errors.New(fmt.Sprintf("number: unsupported value %v", number))
+}
+
+func toJson(v interface{}) (template.JS, error) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return template.JS(bytes), nil
+}
+
+func safeHTML(s string) template.HTML {
+	return template.HTML(s)
+}
+
+func safeAttr(s string) template.HTMLAttr {
+	return template.HTMLAttr(s)
+}
+
+func safeJS(s string) template.JS {
+	return template.JS(s)
+}
+
+func safeURL(s string) template.URL {
+	return template.URL(s)
+}
+
+func noErrors() bool {
+	return false
+}
+
+func noFieldErrors(field string) []string {
+	return nil
+}
@@ -0,0 +1,160 @@
+package trinity
+
+import (
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+const (
+	defaultMaxUploadSize = 32 << 20 // 32 MB
+)
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType     = reflect.TypeOf([]*multipart.FileHeader(nil))
+	multipartReaderType = reflect.TypeOf((*multipart.Reader)(nil))
+)
+
+// SetMaxUploadSize sets the max size of form and multipart request bodies, ActionInfo.MaxUploadSize
+// overrides it for a single action. Larger requests get the 413 status code.
+func (mvcI *MvcInfrastructure) SetMaxUploadSize(size int64) {
+	mvcI.maxUploadSize = size
+}
+
+// uploadSize returns the max upload size of the handler
+func (mvcI *MvcInfrastructure) uploadSize(handler *methodDescriptor) int64 {
+	if handler.maxUploadSize > 0 {
+		return handler.maxUploadSize
+	}
+
+	return mvcI.maxUploadSize
+}
+
+// checkStreaming returns 415 if the action has a *multipart.Reader parameter and the request
+// has no multipart body, nil otherwise
+func checkStreaming(handler *methodDescriptor, request *http.Request) ActionResultInterface {
+	if handler.hasParam(multipartReaderType) && requestFormMediaType(request) != multipartMediaType {
+		logger.Errorf("multipart expected: %s", request.Header.Get("Content-Type"))
+		return bodyErrorResult(errUnsupportedMediaType)
+	}
+
+	return nil
+}
+
+// parseForm parses the form body of the request (see requestFormMediaType) into the invoker values
+// and files. If the action has a *multipart.Reader parameter the multipart body is not parsed,
+// the reader is passed instead (see checkStreaming). Returns the error result if the form can't be parsed.
+func (mvcI *MvcInfrastructure) parseForm(handler *methodDescriptor, invoker *handlerInvoker, request *http.Request) ActionResultInterface {
+	logger.Trace("")
+
+	mediaType := requestFormMediaType(request)
+	streaming := handler.hasParam(multipartReaderType)
+	if mediaType == "" {
+		return nil
+	}
+
+	body := newLimitedBody(request.Body, mvcI.uploadSize(handler))
+	request.Body = struct {
+		io.Reader
+		io.Closer
+	}{body, request.Body}
+
+	var err error
+	switch {
+	case mediaType == urlencodedMediaType:
+		err = parseUrlencodedForm(request)
+	case streaming:
+		logger.Trace("streaming")
+		var reader *multipart.Reader
+		reader, err = request.MultipartReader()
+		if err == nil {
+			invoker.AddParam(reader)
+		}
+	default:
+		err = request.ParseMultipartForm(defaultMaxMemory)
+		if err == nil {
+			invoker.SetFiles(request.MultipartForm.File)
+		}
+	}
+
+	if err != nil {
+		logger.Error(err.Error())
+		if body.exceeded() {
+			return bodyErrorResult(errBodyTooLarge)
+		}
+		return bodyErrorResult(err)
+	}
+
+	invoker.SetSourceValues(FormSource, request.PostForm)
+	return nil
+}
+
+// parseUrlencodedForm parses the urlencoded body into request.PostForm and request.Form.
+// Unlike request.ParseForm it parses bodies of all formMethods, including DELETE.
+func parseUrlencodedForm(request *http.Request) error {
+	data, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+
+	request.PostForm = values
+	return request.ParseForm()
+}
+
+// bindFiles fills the *multipart.FileHeader and []*multipart.FileHeader fields of the struct by
+// the form tag or the field name
+func (invoker *handlerInvoker) bindFiles(input reflect.Value) {
+	if len(invoker.files) == 0 {
+		return
+	}
+
+	structValue := input.Elem()
+	structType := structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" || (field.Type != fileHeaderType && field.Type != fileHeadersType) {
+			continue
+		}
+
+		name := field.Tag.Get(FormSource)
+		if name == "" {
+			name = fieldName(field)
+		}
+
+		headers := invoker.fileHeaders(name)
+		if len(headers) == 0 {
+			continue
+		}
+
+		if field.Type == fileHeaderType {
+			structValue.Field(i).Set(reflect.ValueOf(headers[0]))
+		} else {
+			structValue.Field(i).Set(reflect.ValueOf(headers))
+		}
+	}
+}
+
+// fileHeaders returns the uploaded files with the name (case insensitive)
+func (invoker *handlerInvoker) fileHeaders(name string) []*multipart.FileHeader {
+	if headers, exists := invoker.files[name]; exists {
+		return headers
+	}
+
+	for existing, headers := range invoker.files {
+		if strings.EqualFold(existing, name) {
+			return headers
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package trinity
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// Struct tag with comma separated validation rules:
+	//
+	//	required     - value must not be empty
+	//	min=N, max=N - bounds of a number, or of the length of a string/slice
+	//	len=N        - exact length of a string/slice
+	//	email        - value must be an email address
+	//
+	// Example: `validate:"required,min=3,max=20"`
+	ValidateTag = "validate"
+
+	// Struct tag with a regular expression the string value must match.
+	// Example: `regexp:"^[a-z]+$"`
+	RegexpTag = "regexp"
+
+	regexpEmail = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+	regexpCache     = make(map[string]*regexp.Regexp, 0)
+	regexpCacheLock sync.Mutex
+)
+
+// validate checks struct fields using ValidateTag and RegexpTag rules and adds
+// failures to the model state. Rules, except 'required', are not checked for empty values.
+func validate(value reflect.Value, prefix string, modelState *ModelState) {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		name := prefix + fieldName(field)
+
+		rules := field.Tag.Get(ValidateTag)
+		if rules != "" {
+			validateRules(fieldValue, name, rules, modelState)
+		}
+
+		pattern := field.Tag.Get(RegexpTag)
+		if pattern != "" && fieldValue.Kind() == reflect.String && fieldValue.Len() > 0 {
+			validateRegexp(fieldValue.String(), name, pattern, modelState)
+		}
+
+		if fieldValue.Kind() == reflect.Struct || (fieldValue.Kind() == reflect.Ptr && fieldValue.Type().Elem().Kind() == reflect.Struct) {
+			validate(fieldValue, name+".", modelState)
+		}
+	}
+}
+
+// fieldName returns the name of the value bound to the field: name from the gorilla
+// schema tag or the field name
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("schema"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+
+	return name
+}
+
+func validateRules(value reflect.Value, name string, rules string, modelState *ModelState) {
+	empty := isEmptyValue(value)
+
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		ruleName, ruleArg := rule, ""
+		if eq := strings.Index(rule, "="); eq >= 0 {
+			ruleName, ruleArg = rule[:eq], rule[eq+1:]
+		}
+
+		if ruleName == "required" {
+			if empty {
+				modelState.AddError(name, "is required")
+			}
+			continue
+		}
+
+		if empty {
+			continue
+		}
+
+		switch ruleName {
+		case "min", "max", "len":
+			validateBound(value, name, ruleName, ruleArg, modelState)
+		case "email":
+			if value.Kind() == reflect.String && !regexpEmail.MatchString(value.String()) {
+				modelState.AddError(name, "must be an email address")
+			}
+		default:
+			logger.Warnf("Unknown validation rule %q of %s", ruleName, name)
+		}
+	}
+}
+
+func validateBound(value reflect.Value, name string, ruleName string, ruleArg string, modelState *ModelState) {
+	bound, err := strconv.ParseFloat(ruleArg, 64)
+	if err != nil {
+		logger.Warnf("Wrong argument of the validation rule %q of %s", ruleName, name)
+		return
+	}
+
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	var actual float64
+	isLength := false
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	case reflect.String:
+		actual = float64(len([]rune(value.String())))
+		isLength = true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		actual = float64(value.Len())
+		isLength = true
+	default:
+		return
+	}
+
+	subject := "value"
+	if isLength {
+		subject = "length"
+	}
+
+	switch {
+	case ruleName == "min" && actual < bound:
+		modelState.AddError(name, fmt.Sprintf("%s must be at least %v", subject, bound))
+	case ruleName == "max" && actual > bound:
+		modelState.AddError(name, fmt.Sprintf("%s must be at most %v", subject, bound))
+	case ruleName == "len" && actual != bound:
+		modelState.AddError(name, fmt.Sprintf("%s must be %v", subject, bound))
+	}
+}
+
+func validateRegexp(value string, name string, pattern string, modelState *ModelState) {
+	regexpCacheLock.Lock()
+	compiled, exists := regexpCache[pattern]
+	if !exists {
+		var err error
+		compiled, err = regexp.Compile(pattern)
+		if err != nil {
+			regexpCacheLock.Unlock()
+			logger.Warnf("Wrong regexp of %s: %v", name, err)
+			return
+		}
+		regexpCache[pattern] = compiled
+	}
+	regexpCacheLock.Unlock()
+
+	if !compiled.MatchString(value) {
+		modelState.AddError(name, "has wrong format")
+	}
+}
+
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+
+	return reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface())
+}
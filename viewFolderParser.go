@@ -2,6 +2,7 @@ package trinity
 
 import (
 	"errors"
+	"html/template"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,13 +20,17 @@ var (
 type viewFolderParser struct {
 	viewsFolder string
 	mvcI        *MvcInfrastructure
+	views       map[Controller]map[Action]*templateDescriptor // parsed views are stored here
+	funcs       template.FuncMap                              // funcs available in views
 }
 
-func newViewFolderParser(mvcI *MvcInfrastructure) *viewFolderParser {
+func newViewFolderParser(mvcI *MvcInfrastructure, views map[Controller]map[Action]*templateDescriptor) *viewFolderParser {
 	parser := new(viewFolderParser)
 
 	parser.viewsFolder = mvcI.viewsFolder
 	parser.mvcI = mvcI
+	parser.views = views
+	parser.funcs = mvcI.getTemplateFuncs()
 
 	return parser
 }
@@ -54,7 +59,10 @@ func (parser *viewFolderParser) parse() error {
 			templatePath := filepath.Join(parser.viewsFolder, controllerName, actionName+ViewsSuffix)
 			logger.Debugf("TemplatePath: %v", templatePath)
 
-			parser.mvcI.bindView(controller, action, templatePath)
+			err = parser.mvcI.bindView(parser.views, controller, action, templatePath, parser.funcs)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
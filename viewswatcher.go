@@ -0,0 +1,199 @@
+package trinity
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// viewsWatcher polls the views folder and recompiles views when their files are changed.
+// Used in development to pick up view changes without restarting the server.
+//
+// Dependencies are taken from the templateDescriptor options: if a master page or an
+// additional template is changed then every view that uses it is recompiled. If view files
+// are added or removed, or a changed file is not used by any view, the whole views folder is
+// parsed again.
+type viewsWatcher struct {
+	mvcI     *MvcInfrastructure
+	interval time.Duration
+	modTimes map[string]time.Time // file path -> modification time seen on the last poll
+	stop     chan bool
+}
+
+func newViewsWatcher(mvcI *MvcInfrastructure, interval time.Duration) *viewsWatcher {
+	watcher := new(viewsWatcher)
+
+	watcher.mvcI = mvcI
+	watcher.interval = interval
+	watcher.stop = make(chan bool)
+
+	return watcher
+}
+
+func (watcher *viewsWatcher) start() error {
+	logger.Trace("")
+
+	modTimes, err := watcher.scan()
+	if err != nil {
+		return err
+	}
+	watcher.modTimes = modTimes
+
+	go watcher.run()
+
+	return nil
+}
+
+func (watcher *viewsWatcher) run() {
+	ticker := time.NewTicker(watcher.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-watcher.stop:
+			logger.Trace("views watcher stopped")
+			return
+		case <-ticker.C:
+			watcher.poll()
+		}
+	}
+}
+
+func (watcher *viewsWatcher) poll() {
+	modTimes, err := watcher.scan()
+	if err != nil {
+		logger.Errorf("views watcher: %v", err)
+		return
+	}
+
+	changed := make([]string, 0)
+	viewsSetChanged := false
+
+	for path, modTime := range modTimes {
+		oldModTime, exists := watcher.modTimes[path]
+		if !exists {
+			logger.Debugf("added: %s", path)
+			viewsSetChanged = viewsSetChanged || watcher.isViewFile(path)
+			changed = append(changed, path)
+			continue
+		}
+
+		if !modTime.Equal(oldModTime) {
+			logger.Debugf("changed: %s", path)
+			changed = append(changed, path)
+		}
+	}
+
+	for path := range watcher.modTimes {
+		if _, exists := modTimes[path]; !exists {
+			logger.Debugf("removed: %s", path)
+			viewsSetChanged = viewsSetChanged || watcher.isViewFile(path)
+			changed = append(changed, path)
+		}
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+
+	if viewsSetChanged || !watcher.recompileDependents(changed) {
+		logger.Trace("reload all views")
+		err = watcher.mvcI.loadViews()
+		if err != nil {
+			logger.Errorf("views watcher: %v", err)
+			return
+		}
+	}
+
+	watcher.modTimes = modTimes
+}
+
+// recompileDependents recompiles every view that uses one of the changed files. Returns false
+// if some file isn't used by any view or a view couldn't be recompiled, so the full reload is needed.
+func (watcher *viewsWatcher) recompileDependents(changed []string) bool {
+	mvcI := watcher.mvcI
+
+	dependents := make(map[ControllerAction]*templateDescriptor, 0)
+
+	mvcI.viewsLock.RLock()
+	for _, path := range changed {
+		found := false
+		for c, actions := range mvcI.views {
+			for a, template := range actions {
+				if template.dependsOn(path) {
+					dependents[ControllerAction{c, a}] = template
+					found = true
+				}
+			}
+		}
+
+		if !found {
+			mvcI.viewsLock.RUnlock()
+			logger.Debugf("no views depend on %s", path)
+			return false
+		}
+	}
+	mvcI.viewsLock.RUnlock()
+
+	funcs := mvcI.getTemplateFuncs()
+	recompiled := make(map[ControllerAction]*templateDescriptor, 0)
+	for ca, template := range dependents {
+		logger.Debugf("recompile: %v/%v", ca.C, ca.A)
+
+		newTemplate, err := newTemplateDescriptor(mvcI.viewsFolder, template.templatePath, funcs)
+		if err != nil {
+			logger.Errorf("views watcher: %v", err)
+			return false
+		}
+
+		recompiled[ca] = newTemplate
+	}
+
+	mvcI.viewsLock.Lock()
+	for ca, template := range recompiled {
+		if actions, exists := mvcI.views[ca.C]; exists {
+			actions[ca.A] = template
+		}
+	}
+	mvcI.viewsLock.Unlock()
+
+	return true
+}
+
+// isViewFile returns true if the path is an action view in a controller folder
+func (watcher *viewsWatcher) isViewFile(path string) bool {
+	if !strings.HasSuffix(path, ViewsSuffix) {
+		return false
+	}
+
+	rel, err := filepath.Rel(watcher.mvcI.viewsFolder, path)
+	if err != nil {
+		return false
+	}
+
+	return len(strings.Split(rel, string(filepath.Separator))) == 2
+}
+
+// scan returns modification times of all files in the views folder
+func (watcher *viewsWatcher) scan() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, 0)
+
+	err := filepath.Walk(watcher.mvcI.viewsFolder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			modTimes[filepath.Clean(path)] = info.ModTime()
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return modTimes, nil
+}